@@ -0,0 +1,194 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCommands = []CommandSpec{
+	{Name: "get", Categories: []string{"read", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "set", Categories: []string{"write", "string", "slow"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "del", Categories: []string{"keyspace", "write", "slow"}, FirstKey: 1, LastKey: -1, KeyStep: 1},
+	{Name: "auth", Categories: []string{"fast", "connection"}, NoAuth: true},
+	{Name: "config", Categories: []string{"admin", "slow", "dangerous"}},
+}
+
+// Check whether user may run args, failing the test for unknown users
+func check(t *testing.T, us *Users, user string, args ...string) *Denial {
+	u, ok := us.Lookup(user)
+	if !ok {
+		t.Fatalf("no user %s", user)
+	}
+	spec, _ := us.Command(args[0])
+	return us.Check(u, spec, args)
+}
+
+func TestCommandSpecKeys(t *testing.T) {
+	del := testCommands[2]
+	assert.Equal(t, []string{"a", "b", "c"}, del.Keys([]string{"del", "a", "b", "c"}))
+	assert.Nil(t, del.Keys([]string{"del"}))
+	config := testCommands[4]
+	assert.Nil(t, config.Keys([]string{"config", "get", "port"}))
+}
+
+func TestDefaultUser(t *testing.T) {
+	us := NewUsers(testCommands)
+	assert.True(t, us.NoPass(DefaultUser))
+	description, _ := us.Describe(DefaultUser)
+	assert.Equal(t, "user default on nopass ~* &* +@all", description)
+	assert.Nil(t, check(t, us, DefaultUser, "config", "set", "timeout", "1"))
+	_, err := us.DeleteUsers(DefaultUser)
+	assert.Equal(t, ErrDeleteDefaultUser, err)
+
+	us.SetDefaultPassword("secret")
+	assert.False(t, us.NoPass(DefaultUser))
+	_, ok := us.Authenticate(DefaultUser, "wrong")
+	assert.False(t, ok)
+	_, ok = us.Authenticate(DefaultUser, "secret")
+	assert.True(t, ok)
+	us.SetDefaultPassword("")
+	_, ok = us.Authenticate(DefaultUser, "anything")
+	assert.True(t, ok)
+}
+
+func TestSetUser(t *testing.T) {
+	us := NewUsers(testCommands)
+	assert.Nil(t, us.SetUser("alice", "on", ">secret", "~cache:*", "&news.*", "+@read", "+del", "-get"))
+	description, _ := us.Describe("alice")
+	assert.Equal(t, "user alice on #2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b ~cache:* &news.* -@all +@read +del -get", description)
+	info, _ := us.Info("alice")
+	assert.Equal(t, []string{"on"}, info.Flags)
+	assert.Equal(t, "~cache:*", info.Keys)
+	assert.Equal(t, "&news.*", info.Channels)
+	assert.Equal(t, "-@all +@read +del -get", info.Commands)
+
+	_, ok := us.Authenticate("alice", "secret")
+	assert.True(t, ok)
+	assert.Equal(t, &Denial{Reason: ReasonCommand, Object: "get", Username: "alice"}, check(t, us, "alice", "get", "cache:1"))
+	assert.Equal(t, &Denial{Reason: ReasonCommand, Object: "set", Username: "alice"}, check(t, us, "alice", "set", "cache:1", "v"))
+	assert.Nil(t, check(t, us, "alice", "del", "cache:1", "cache:2"))
+	assert.Equal(t, &Denial{Reason: ReasonKey, Object: "other", Username: "alice"}, check(t, us, "alice", "del", "cache:1", "other"))
+	assert.Nil(t, check(t, us, "alice", "auth", "alice", "wrong"), "Commands that run before authenticating are always allowed")
+	u, _ := us.Lookup("alice")
+	assert.Nil(t, us.CheckChannel(u, "news.tech"))
+	assert.NotNil(t, us.CheckChannel(u, "sports"))
+
+	assert.Nil(t, us.SetUser("alice", "+get", "<secret", "#"+hashPassword("other")))
+	assert.Nil(t, check(t, us, "alice", "get", "cache:1"), "Changes must apply to users looked up before")
+	_, ok = us.Authenticate("alice", "secret")
+	assert.False(t, ok)
+	_, ok = us.Authenticate("alice", "other")
+	assert.True(t, ok)
+	assert.Nil(t, us.SetUser("alice", "off"))
+	_, ok = us.Authenticate("alice", "other")
+	assert.False(t, ok, "Disabled users must not authenticate")
+
+	assert.Nil(t, us.SetUser("bob"))
+	info, _ = us.Info("bob")
+	assert.Equal(t, []string{"off"}, info.Flags, "New users must be off")
+	assert.Equal(t, "-@all", info.Commands)
+	assert.Equal(t, []string{"alice", "bob", "default"}, us.Names())
+
+	deleted, err := us.DeleteUsers("bob", "nobody")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	us.DeleteUsers("alice")
+	assert.True(t, us.Deleted(u), "Users must know when they are deleted")
+}
+
+func TestSetUserErrors(t *testing.T) {
+	us := NewUsers(testCommands)
+	errors := map[string]string{
+		"+nosuchcommand": "Unknown command or category name in ACL",
+		"+@nosuchcat":    "Unknown command or category name in ACL",
+		"+config|get":    "Allowing first-arg of a subcommand is not supported",
+		"<nosuchpass":    "The password you are trying to remove from the user does not exist",
+		"#abc":           "The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters",
+		"bogus":          "Syntax error",
+	}
+	for rule, message := range errors {
+		err := us.SetUser("alice", "on", rule)
+		assert.Equal(t, "Error in ACL SETUSER modifier '"+rule+"': "+message, err.Error())
+	}
+	_, ok := us.Lookup("alice")
+	assert.False(t, ok, "Users must not be created by failed rules")
+	assert.NotNil(t, us.SetUser("alice", "allkeys", "~foo"))
+	assert.NotNil(t, us.SetUser("alice", "allchannels", "&foo"))
+
+	assert.Nil(t, us.SetUser("carol", "on", "+get"))
+	assert.NotNil(t, us.SetUser("carol", "+set", "+nosuchcommand"))
+	assert.NotNil(t, check(t, us, "carol", "set", "k", "v"), "Failed rules must not change anything")
+}
+
+func TestCategories(t *testing.T) {
+	us := NewUsers(testCommands)
+	names, ok := us.Commands("write")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"del", "set"}, names)
+	names, ok = us.Commands("pubsub")
+	assert.True(t, ok)
+	assert.Empty(t, names)
+	_, ok = us.Commands("nosuchcat")
+	assert.False(t, ok)
+
+	assert.Nil(t, us.SetUser("alice", "on", "allkeys", "+@all", "-@dangerous"))
+	assert.Nil(t, check(t, us, "alice", "set", "k", "v"))
+	assert.NotNil(t, check(t, us, "alice", "config", "get", "port"))
+	info, _ := us.Info("alice")
+	assert.Equal(t, "+@all -@dangerous", info.Commands)
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "redis-mock-acl")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.acl")
+	ioutil.WriteFile(path, []byte("# Users\nuser alice on >secret ~* +@all\n\nuser default on >admin ~* +@all\n"), 0600)
+
+	us := NewUsers(testCommands)
+	us.SetUser("bob", "on", "nopass")
+	bob, _ := us.Lookup("bob")
+	assert.Nil(t, us.LoadFile(path))
+	assert.Equal(t, []string{"alice", "default"}, us.Names())
+	assert.True(t, us.Deleted(bob), "Users missing from the file must be deleted")
+	_, ok := us.Authenticate("default", "admin")
+	assert.True(t, ok)
+
+	ioutil.WriteFile(path, []byte("user alice on\nuser alice off\n"), 0600)
+	assert.Equal(t, path+":2: Duplicate user 'alice' found", us.LoadFile(path).Error())
+	ioutil.WriteFile(path, []byte("user alice on +nosuchcommand\n"), 0600)
+	assert.Equal(t, path+":1: Unknown command or category name in ACL. Error in user declaration 'alice'", us.LoadFile(path).Error())
+	ioutil.WriteFile(path, []byte("alice on\n"), 0600)
+	assert.NotNil(t, us.LoadFile(path))
+	_, ok = us.Authenticate("alice", "secret")
+	assert.True(t, ok, "Files with errors must not change anything")
+
+	ioutil.WriteFile(path, []byte("user alice on nopass\n"), 0600)
+	assert.Nil(t, us.LoadFile(path))
+	assert.True(t, us.NoPass(DefaultUser), "The default user must be created if the file lacks it")
+}
+
+func TestLog(t *testing.T) {
+	var l Log
+	denied := &Denial{Reason: ReasonKey, Object: "secret", Username: "alice"}
+	l.Add(denied, "toplevel", "id=1", 2)
+	l.Add(&Denial{Reason: ReasonAuth, Object: "AUTH", Username: "bob"}, "toplevel", "id=2", 2)
+	l.Add(denied, "toplevel", "id=3", 2)
+	entries := l.Entries(-1)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, int64(2), entries[0].Count, "Similar denials must be counted in one entry")
+	assert.Equal(t, "id=3", entries[0].ClientInfo)
+	assert.Equal(t, int64(0), entries[0].ID)
+	assert.Equal(t, "bob", entries[1].Username)
+
+	l.Add(&Denial{Reason: ReasonCommand, Object: "get", Username: "carol"}, "toplevel", "id=4", 2)
+	entries = l.Entries(10)
+	assert.Len(t, entries, 2, "The log must not grow beyond its maximum length")
+	assert.Equal(t, int64(2), entries[0].ID)
+	assert.Len(t, l.Entries(1), 1)
+	l.Reset()
+	assert.Empty(t, l.Entries(-1))
+}
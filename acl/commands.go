@@ -0,0 +1,74 @@
+// Package acl implements the access control lists of Redis 6: users, the
+// passwords they authenticate with, and the commands, keys and pub/sub
+// channels they may use. Rules are written like in ACL SETUSER and ACL files.
+package acl
+
+// Categories are the command categories of Redis, without the @. Rules may
+// use any of them, even those no command of the mock belongs to yet
+var Categories = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string",
+	"bitmap", "hyperloglog", "geo", "stream", "pubsub", "admin", "fast",
+	"slow", "blocking", "dangerous", "connection", "transaction", "scripting",
+}
+
+// CommandSpec describes a command to the permission checks
+type CommandSpec struct {
+	// Lower case name of the command
+	Name string
+	// Categories the command belongs to
+	Categories []string
+	// Positions of the first and last key in the arguments, the command
+	// being at 0, and the step between keys. A negative LastKey counts from
+	// the end, and a FirstKey of 0 means there are no keys
+	FirstKey int
+	LastKey  int
+	KeyStep  int
+	// Whether the command may be run before authenticating, in which case
+	// it is never denied
+	NoAuth bool
+}
+
+// Keys returns the keys args refers to, args[0] being the command
+func (c *CommandSpec) Keys(args []string) []string {
+	if c.FirstKey <= 0 || c.FirstKey >= len(args) {
+		return nil
+	}
+	last := c.LastKey
+	if last < 0 {
+		last += len(args)
+	}
+	if last >= len(args) {
+		last = len(args) - 1
+	}
+	var keys []string
+	for i := c.FirstKey; i <= last; i += c.KeyStep {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+// Whether the command belongs to category
+func (c *CommandSpec) inCategory(category string) bool {
+	if category == "all" {
+		return true
+	}
+	for _, name := range c.Categories {
+		if name == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether category is one of Categories, or all
+func isCategory(category string) bool {
+	if category == "all" {
+		return true
+	}
+	for _, name := range Categories {
+		if name == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package acl
+
+import (
+	"sync"
+	"time"
+)
+
+// Denials of the same kind within this long of each other are counted in one
+// entry, like in Redis
+const logGroupingInterval = 60 * time.Second
+
+// LogEntry is a denial recorded in the log
+type LogEntry struct {
+	Count int64
+	Denial
+	// Where the denied command ran, toplevel for commands sent by clients
+	Context string
+	// The client that was denied, in the format of CLIENT INFO
+	ClientInfo string
+	// Unique for the lifetime of the log
+	ID      int64
+	Created time.Time
+	Updated time.Time
+}
+
+// Log records the latest denials, most recent first, for ACL LOG
+type Log struct {
+	mu      sync.Mutex
+	entries []*LogEntry
+	nextID  int64
+}
+
+// Add records a denial, keeping the log at maxLen entries. A denial like one
+// recorded less than a minute ago only counts it again
+func (l *Log) Add(d *Denial, context string, clientInfo string, maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for i, e := range l.entries {
+		if e.Denial == *d && e.Context == context && now.Sub(e.Updated) < logGroupingInterval {
+			e.Count++
+			e.Updated = now
+			e.ClientInfo = clientInfo
+			copy(l.entries[1:i+1], l.entries[:i])
+			l.entries[0] = e
+			return
+		}
+	}
+	e := &LogEntry{
+		Count:      1,
+		Denial:     *d,
+		Context:    context,
+		ClientInfo: clientInfo,
+		ID:         l.nextID,
+		Created:    now,
+		Updated:    now,
+	}
+	l.nextID++
+	l.entries = append([]*LogEntry{e}, l.entries...)
+	if len(l.entries) > maxLen {
+		l.entries = l.entries[:maxLen]
+	}
+}
+
+// Entries returns up to count of the most recent entries, or every entry if
+// count is negative
+func (l *Log) Entries(count int) []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if count < 0 || count > len(l.entries) {
+		count = len(l.entries)
+	}
+	entries := make([]LogEntry, count)
+	for i := range entries {
+		entries[i] = *l.entries[i]
+	}
+	return entries
+}
+
+// Reset removes every entry
+func (l *Log) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+}
@@ -0,0 +1,305 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Errors of rules, in the words of Redis
+var (
+	errSyntax          = errors.New("Syntax error")
+	errUnknownCommand  = errors.New("Unknown command or category name in ACL")
+	errKeyAfterAll     = errors.New("Adding a pattern after the * pattern (or the 'allkeys' flag) is not valid and does not have any effect. Try 'resetkeys' to start with an empty list of patterns")
+	errChannelAfterAll = errors.New("Adding a pattern after the * pattern (or the 'allchannels' flag) is not valid and does not have any effect. Try 'resetchannels' to start with an empty list of channels")
+	errNoSuchPassword  = errors.New("The password you are trying to remove from the user does not exist")
+	errInvalidHash     = errors.New("The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+	errSubcommandRules = errors.New("Allowing first-arg of a subcommand is not supported")
+)
+
+// User is a set of credentials and permissions. Users are changed in place
+// by Users.SetUser, so connections authenticated as a user see the changes
+// to it right away
+type User struct {
+	name    string
+	enabled bool
+	// Any password authenticates the user
+	noPass bool
+	// SHA-256 hashes of the passwords, in hex, in the order they were added
+	passwords []string
+
+	// Set by +@all, until a command is removed. Commands unknown to the
+	// permission checks are only allowed then
+	allCommands bool
+	allowed     map[string]bool
+	// The command rules that led to allowed, to describe the user
+	commandRules []string
+
+	allKeys     bool
+	keys        []string
+	allChannels bool
+	channels    []string
+
+	// Set once the user was removed, its connections must be closed
+	deleted bool
+}
+
+// Create a user with no permissions, which is off until enabled
+func newUser(name string) *User {
+	return &User{name: name, allowed: map[string]bool{}, commandRules: []string{"-@all"}}
+}
+
+// Name returns the name of the user
+func (u *User) Name() string {
+	return u.name
+}
+
+// Copy the user, so rules can be tried without changing it
+func (u *User) clone() *User {
+	c := *u
+	c.passwords = append([]string(nil), u.passwords...)
+	c.allowed = make(map[string]bool, len(u.allowed))
+	for name, allowed := range u.allowed {
+		c.allowed[name] = allowed
+	}
+	c.commandRules = append([]string(nil), u.commandRules...)
+	c.keys = append([]string(nil), u.keys...)
+	c.channels = append([]string(nil), u.channels...)
+	return &c
+}
+
+// Hash a password the way ACL files and ACL GETUSER show it
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// Whether hash could be the output of hashPassword
+func isHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Add a password hash, unless the user has it already
+func (u *User) addPassword(hash string) {
+	u.noPass = false
+	for _, p := range u.passwords {
+		if p == hash {
+			return
+		}
+	}
+	u.passwords = append(u.passwords, hash)
+}
+
+// Remove a password hash
+func (u *User) removePassword(hash string) error {
+	for i, p := range u.passwords {
+		if p == hash {
+			u.passwords = append(u.passwords[:i], u.passwords[i+1:]...)
+			return nil
+		}
+	}
+	return errNoSuchPassword
+}
+
+// Allow or deny every command of category, and every command to come if the
+// category is all
+func (u *User) setCategory(category string, allow bool, commands map[string]*CommandSpec) {
+	for name, spec := range commands {
+		if spec.inCategory(category) {
+			u.allowed[name] = allow
+		}
+	}
+	if category == "all" {
+		u.allCommands = allow
+		if allow {
+			u.commandRules = []string{"+@all"}
+		} else {
+			u.commandRules = []string{"-@all"}
+		}
+		return
+	}
+	if !allow {
+		u.allCommands = false
+	}
+	u.commandRules = append(u.commandRules, ruleSign(allow)+"@"+category)
+}
+
+// Allow or deny a single command. A rule for the same command given before
+// is replaced, since it no longer has any effect
+func (u *User) setCommand(name string, allow bool) {
+	u.allowed[name] = allow
+	if !allow {
+		u.allCommands = false
+	}
+	for i, rule := range u.commandRules {
+		if rule[1:] == name {
+			u.commandRules = append(u.commandRules[:i], u.commandRules[i+1:]...)
+			break
+		}
+	}
+	u.commandRules = append(u.commandRules, ruleSign(allow)+name)
+}
+
+func ruleSign(allow bool) string {
+	if allow {
+		return "+"
+	}
+	return "-"
+}
+
+// Apply one rule of ACL SETUSER to the user
+func (u *User) apply(rule string, commands map[string]*CommandSpec) error {
+	switch strings.ToLower(rule) {
+	case "on":
+		u.enabled = true
+		return nil
+	case "off":
+		u.enabled = false
+		return nil
+	case "nopass":
+		u.noPass = true
+		u.passwords = nil
+		return nil
+	case "resetpass":
+		u.noPass = false
+		u.passwords = nil
+		return nil
+	case "allkeys", "~*":
+		u.allKeys = true
+		u.keys = []string{"*"}
+		return nil
+	case "resetkeys":
+		u.allKeys = false
+		u.keys = nil
+		return nil
+	case "allchannels", "&*":
+		u.allChannels = true
+		u.channels = []string{"*"}
+		return nil
+	case "resetchannels":
+		u.allChannels = false
+		u.channels = nil
+		return nil
+	case "allcommands":
+		u.setCategory("all", true, commands)
+		return nil
+	case "nocommands":
+		u.setCategory("all", false, commands)
+		return nil
+	case "reset":
+		for _, r := range []string{"resetpass", "resetkeys", "resetchannels", "off", "-@all"} {
+			u.apply(r, commands)
+		}
+		return nil
+	}
+	if rule == "" {
+		return errSyntax
+	}
+	switch rule[0] {
+	case '>':
+		u.addPassword(hashPassword(rule[1:]))
+	case '<':
+		return u.removePassword(hashPassword(rule[1:]))
+	case '#':
+		if !isHash(rule[1:]) {
+			return errInvalidHash
+		}
+		u.addPassword(rule[1:])
+	case '!':
+		if !isHash(rule[1:]) {
+			return errInvalidHash
+		}
+		return u.removePassword(rule[1:])
+	case '~':
+		if u.allKeys {
+			return errKeyAfterAll
+		}
+		u.keys = append(u.keys, rule[1:])
+	case '&':
+		if u.allChannels {
+			return errChannelAfterAll
+		}
+		u.channels = append(u.channels, rule[1:])
+	case '+', '-':
+		allow := rule[0] == '+'
+		name := strings.ToLower(rule[1:])
+		if strings.HasPrefix(name, "@") {
+			if !isCategory(name[1:]) {
+				return errUnknownCommand
+			}
+			u.setCategory(name[1:], allow, commands)
+			return nil
+		}
+		if strings.Contains(name, "|") {
+			if _, ok := commands[name[:strings.Index(name, "|")]]; ok {
+				return errSubcommandRules
+			}
+			return errUnknownCommand
+		}
+		if _, ok := commands[name]; !ok {
+			return errUnknownCommand
+		}
+		u.setCommand(name, allow)
+	default:
+		return errSyntax
+	}
+	return nil
+}
+
+// Flags of the user, as ACL GETUSER lists them
+func (u *User) flags() []string {
+	flags := []string{"off"}
+	if u.enabled {
+		flags[0] = "on"
+	}
+	if u.noPass {
+		flags = append(flags, "nopass")
+	}
+	return flags
+}
+
+// Describe the key patterns as rules
+func (u *User) keyRules() string {
+	rules := make([]string, len(u.keys))
+	for i, pattern := range u.keys {
+		rules[i] = "~" + pattern
+	}
+	return strings.Join(rules, " ")
+}
+
+// Describe the channel patterns as rules
+func (u *User) channelRules() string {
+	rules := make([]string, len(u.channels))
+	for i, pattern := range u.channels {
+		rules[i] = "&" + pattern
+	}
+	return strings.Join(rules, " ")
+}
+
+// Describe the user as the rules that recreate it, the way ACL LIST and ACL
+// files do
+func (u *User) describe() string {
+	parts := append([]string{"user", u.name}, u.flags()...)
+	for _, hash := range u.passwords {
+		parts = append(parts, "#"+hash)
+	}
+	if keys := u.keyRules(); keys != "" {
+		parts = append(parts, keys)
+	}
+	if channels := u.channelRules(); channels != "" {
+		parts = append(parts, channels)
+	} else {
+		parts = append(parts, "resetchannels")
+	}
+	parts = append(parts, u.commandRules...)
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,351 @@
+package acl
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"golang-redis-mock/glob"
+	"golang-redis-mock/resp"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultUser is the user connections start out as. It exists on every
+// server, and requirepass sets its password
+const DefaultUser = "default"
+
+// ErrDeleteDefaultUser is returned when removing DefaultUser
+var ErrDeleteDefaultUser = errors.New("The 'default' user cannot be removed")
+
+// Reasons a command may be denied for
+const (
+	ReasonCommand = "command"
+	ReasonKey     = "key"
+	ReasonChannel = "channel"
+	ReasonAuth    = "auth"
+)
+
+// Denial tells why a user may not run a command
+type Denial struct {
+	// One of the Reason constants
+	Reason string
+	// The command, key or channel denied. AUTH for authentication failures
+	Object string
+	// The user denied
+	Username string
+}
+
+// Error describes the denial like Redis does in -NOPERM errors
+func (d *Denial) Error() string {
+	switch d.Reason {
+	case ReasonCommand:
+		return fmt.Sprintf("User %s has no permissions to run the '%s' command", d.Username, d.Object)
+	case ReasonKey:
+		return "No permissions to access a key"
+	case ReasonChannel:
+		return "No permissions to access a channel"
+	}
+	return "invalid username-password pair or user is disabled."
+}
+
+// Verbose describes the denial like Error, naming the key or channel
+func (d *Denial) Verbose() string {
+	switch d.Reason {
+	case ReasonKey:
+		return fmt.Sprintf("No permissions to access the '%s' key", d.Object)
+	case ReasonChannel:
+		return fmt.Sprintf("No permissions to access the '%s' channel", d.Object)
+	}
+	return d.Error()
+}
+
+// UserInfo describes a user in reply to ACL GETUSER
+type UserInfo struct {
+	Flags     []string      `resp:"flags"`
+	Passwords []string      `resp:"passwords"`
+	Commands  string        `resp:"commands"`
+	Keys      string        `resp:"keys"`
+	Channels  string        `resp:"channels"`
+	Selectors []interface{} `resp:"selectors"`
+}
+
+// Users holds the users of a server, and checks what they may do
+type Users struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	// Commands the permission checks know, by lower case name
+	commands map[string]*CommandSpec
+}
+
+// NewUsers creates the users of a server running commands, which is only the
+// default user allowed to do anything without a password
+func NewUsers(commands []CommandSpec) *Users {
+	us := &Users{commands: map[string]*CommandSpec{}}
+	for i := range commands {
+		us.commands[commands[i].Name] = &commands[i]
+	}
+	us.users = map[string]*User{DefaultUser: us.newDefaultUser()}
+	return us
+}
+
+// Create the default user as it is before any rule changes it
+func (us *Users) newDefaultUser() *User {
+	u := newUser(DefaultUser)
+	for _, rule := range []string{"on", "nopass", "~*", "&*", "+@all"} {
+		u.apply(rule, us.commands)
+	}
+	return u
+}
+
+// Lookup returns the user called name
+func (us *Users) Lookup(name string) (*User, bool) {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	u, ok := us.users[name]
+	return u, ok
+}
+
+// Names returns the names of every user, sorted
+func (us *Users) Names() []string {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	names := make([]string, 0, len(us.users))
+	for name := range us.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetUser applies rules to the user called name, creating it if needed.
+// Either every rule is applied or, if one is invalid, none of them
+func (us *Users) SetUser(name string, rules ...string) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	u, ok := us.users[name]
+	if !ok {
+		u = newUser(name)
+	}
+	changed := u.clone()
+	for _, rule := range rules {
+		if err := changed.apply(rule, us.commands); err != nil {
+			return fmt.Errorf("Error in ACL SETUSER modifier '%s': %s", rule, err.Error())
+		}
+	}
+	*u = *changed
+	us.users[name] = u
+	return nil
+}
+
+// DeleteUsers removes the users called names, and returns how many existed.
+// Connections authenticated as them should be closed
+func (us *Users) DeleteUsers(names ...string) (int, error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	for _, name := range names {
+		if name == DefaultUser {
+			return 0, ErrDeleteDefaultUser
+		}
+	}
+	deleted := 0
+	for _, name := range names {
+		if u, ok := us.users[name]; ok {
+			u.deleted = true
+			delete(us.users, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Deleted reports whether u was removed since it was looked up
+func (us *Users) Deleted(u *User) bool {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	return u.deleted
+}
+
+// SetDefaultPassword makes password the only password of the default user,
+// the way requirepass does. An empty password lets anyone in
+func (us *Users) SetDefaultPassword(password string) {
+	if password == "" {
+		us.SetUser(DefaultUser, "nopass")
+		return
+	}
+	us.SetUser(DefaultUser, "resetpass", ">"+password)
+}
+
+// NoPass reports whether the user called name is enabled and takes any
+// password. Connections are authenticated as the default user right away
+// when it does
+func (us *Users) NoPass(name string) bool {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	u, ok := us.users[name]
+	return ok && u.enabled && u.noPass
+}
+
+// Authenticate returns the user called name if it is enabled and password
+// is one of its passwords
+func (us *Users) Authenticate(name string, password string) (*User, bool) {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	u, ok := us.users[name]
+	if !ok || !u.enabled {
+		return nil, false
+	}
+	if u.noPass {
+		return u, true
+	}
+	hash := hashPassword(password)
+	for _, p := range u.passwords {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(p)) == 1 {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// Command returns the command called name, in any case, if the permission
+// checks know it
+func (us *Users) Command(name string) (*CommandSpec, bool) {
+	spec, ok := us.commands[strings.ToLower(name)]
+	return spec, ok
+}
+
+// Commands returns the names of the commands in category, sorted
+func (us *Users) Commands(category string) ([]string, bool) {
+	if !isCategory(category) {
+		return nil, false
+	}
+	names := []string{}
+	for name, spec := range us.commands {
+		if spec.inCategory(category) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// Check returns why u may not run the command args, args[0] being the
+// command, or nil if it may
+func (us *Users) Check(u *User, spec *CommandSpec, args []string) *Denial {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	if spec.NoAuth {
+		return nil
+	}
+	if !u.allCommands && !u.allowed[spec.Name] {
+		return &Denial{Reason: ReasonCommand, Object: spec.Name, Username: u.name}
+	}
+	if u.allKeys {
+		return nil
+	}
+	for _, key := range spec.Keys(args) {
+		if !matchAny(u.keys, key) {
+			return &Denial{Reason: ReasonKey, Object: key, Username: u.name}
+		}
+	}
+	return nil
+}
+
+// CheckChannel returns why u may not use channel, or nil if it may
+func (us *Users) CheckChannel(u *User, channel string) *Denial {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	if u.allChannels || matchAny(u.channels, channel) {
+		return nil
+	}
+	return &Denial{Reason: ReasonChannel, Object: channel, Username: u.name}
+}
+
+// Whether name matches one of the glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if glob.Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe returns the user called name as the rules that recreate it, the
+// way ACL LIST shows it
+func (us *Users) Describe(name string) (string, bool) {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	u, ok := us.users[name]
+	if !ok {
+		return "", false
+	}
+	return u.describe(), true
+}
+
+// Info returns the user called name the way ACL GETUSER shows it
+func (us *Users) Info(name string) (*UserInfo, bool) {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	u, ok := us.users[name]
+	if !ok {
+		return nil, false
+	}
+	return &UserInfo{
+		Flags:     u.flags(),
+		Passwords: append([]string{}, u.passwords...),
+		Commands:  strings.Join(u.commandRules, " "),
+		Keys:      u.keyRules(),
+		Channels:  u.channelRules(),
+		Selectors: []interface{}{},
+	}, true
+}
+
+// LoadFile replaces the users with those of an ACL file, where every line
+// is "user <name> [rule ...]". Blank lines and comments starting with # are
+// skipped. The default user is created as usual if the file does not define
+// it. Users that are still defined keep their connections, and nothing
+// changes if the file has an error.
+func (us *Users) LoadFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	loaded := &Users{users: map[string]*User{}, commands: us.commands}
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		args, ok := resp.SplitArgs(line)
+		if !ok || len(args) < 2 || args[0] != "user" {
+			return fmt.Errorf("%s:%d: line should start with user keyword", path, i+1)
+		}
+		if _, ok := loaded.users[args[1]]; ok {
+			return fmt.Errorf("%s:%d: Duplicate user '%s' found", path, i+1, args[1])
+		}
+		u := newUser(args[1])
+		for _, rule := range args[2:] {
+			if err := u.apply(rule, us.commands); err != nil {
+				return fmt.Errorf("%s:%d: %s. Error in user declaration '%s'", path, i+1, err.Error(), args[1])
+			}
+		}
+		loaded.users[args[1]] = u
+	}
+	if _, ok := loaded.users[DefaultUser]; !ok {
+		loaded.users[DefaultUser] = us.newDefaultUser()
+	}
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	for name, u := range us.users {
+		if changed, ok := loaded.users[name]; ok {
+			*u = *changed
+			loaded.users[name] = u
+		} else {
+			u.deleted = true
+		}
+	}
+	us.users = loaded.users
+	return nil
+}
@@ -0,0 +1,196 @@
+// Package client talks to the mock server, or any Redis server, using the
+// resp package for both directions. Commands go out as RESP arrays of bulk
+// strings and replies come back as resp.IDataType values, which the helpers
+// in reply.go turn into Go values.
+package client
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"golang-redis-mock/resp"
+	"net"
+	"strconv"
+)
+
+// Conn is a single connection to a server. A Conn is not safe for concurrent
+// use, use a Pool to share connections between goroutines.
+type Conn struct {
+	conn    net.Conn
+	writer  *bufio.Writer
+	encoder *resp.Encoder
+	reader  *resp.Reader
+	// Number of commands sent whose replies have not been received yet
+	pending int
+	// First network or protocol error, after which the connection is unusable
+	err error
+}
+
+// Dial connects to the server at address on the named network, like net.Dial
+func Dial(network string, address string) (*Conn, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+// DialTLS connects to the server at address over TLS, like tls.Dial. config
+// holds the certificate authorities to trust and, for servers that ask for
+// them, the client certificates
+func DialTLS(network string, address string, config *tls.Config) (*Conn, error) {
+	conn, err := tls.Dial(network, address, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+// NewConn wraps an established connection
+func NewConn(conn net.Conn) *Conn {
+	writer := bufio.NewWriter(conn)
+	return &Conn{
+		conn:    conn,
+		writer:  writer,
+		encoder: resp.NewEncoder(writer),
+		reader:  resp.NewReader(conn),
+	}
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	if c.err == nil {
+		c.err = errors.New("client: connection closed")
+	}
+	return c.conn.Close()
+}
+
+// Err returns the error that made the connection unusable, if any
+func (c *Conn) Err() error {
+	return c.err
+}
+
+// Do sends a command and waits for its reply. Replies to commands queued with
+// Send are read and discarded first. If the server replies with an error, it
+// is returned as a resp.RedisError, which leaves the connection usable.
+func (c *Conn) Do(cmd string, args ...interface{}) (resp.IDataType, error) {
+	if err := c.Send(cmd, args...); err != nil {
+		return nil, err
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	for c.pending > 1 {
+		if _, err := c.Receive(); err != nil {
+			if _, ok := err.(resp.RedisError); !ok {
+				return nil, err
+			}
+		}
+	}
+	return c.Receive()
+}
+
+// Send queues a command without waiting for its reply. Together with Flush
+// and Receive it pipelines commands: send any number of them, flush once and
+// receive the replies in the same order.
+func (c *Conn) Send(cmd string, args ...interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	command, err := buildCommand(cmd, args)
+	if err != nil {
+		return err
+	}
+	if err := c.encoder.Encode(command); err != nil {
+		return c.fatal(err)
+	}
+	c.pending++
+	return nil
+}
+
+// Flush writes all queued commands to the server
+func (c *Conn) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.writer.Flush(); err != nil {
+		return c.fatal(err)
+	}
+	return nil
+}
+
+// Receive reads the reply to the oldest command sent. Error replies are
+// returned as a resp.RedisError.
+func (c *Conn) Receive() (resp.IDataType, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	reply, err := c.reader.ReadValue()
+	if err != nil {
+		return nil, c.fatal(err)
+	}
+	if c.pending > 0 {
+		c.pending--
+	}
+	if e, ok := reply.(resp.RedisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// Remember the first error that broke the connection
+func (c *Conn) fatal(err error) error {
+	if c.err == nil {
+		c.err = err
+		c.conn.Close()
+	}
+	return err
+}
+
+// Build the RESP array for a command. Every argument is sent as a bulk
+// string, like redis-cli does
+func buildCommand(cmd string, args []interface{}) (*resp.Array, error) {
+	command, _ := resp.NewArray(len(args) + 1)
+	name, err := resp.NewBulkString(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetItemAtIndex(0, name)
+	for i, arg := range args {
+		bs, err := resp.NewBulkStringFromBytes(argumentBytes(arg))
+		if err != nil {
+			return nil, err
+		}
+		command.SetItemAtIndex(i+1, bs)
+	}
+	return command, nil
+}
+
+// Get the bytes sent for an argument. Numbers are formatted the way Redis
+// parses them, anything else unknown by its default format
+func argumentBytes(arg interface{}) []byte {
+	switch v := arg.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	case int:
+		return []byte(strconv.Itoa(v))
+	case int64:
+		return []byte(strconv.FormatInt(v, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	case nil:
+		return []byte{}
+	case resp.IDataType:
+		return []byte(v.ToString())
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"golang-redis-mock/resp"
+	"golang-redis-mock/server"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startServer starts a server with its own keyspace on a random local port.
+// It is closed when the test ends
+func startServer(t *testing.T) *server.Server {
+	s := server.New(server.Options{})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func dial(t *testing.T) *Conn {
+	c, err := Dial("tcp", startServer(t).Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConnDo(t *testing.T) {
+	c := dial(t)
+	defer c.Close()
+	reply, err := c.Do("SET", "conn:do", "bar baz")
+	assert.Nil(t, err)
+	assert.Equal(t, resp.NewString("OK"), reply)
+	value, err := String(c.Do("GET", "conn:do"))
+	assert.Nil(t, err)
+	assert.Equal(t, "bar baz", value)
+	_, err = String(c.Do("GET", "conn:missing"))
+	assert.Equal(t, ErrNil, err)
+}
+
+func TestConnDoBinaryArguments(t *testing.T) {
+	c := dial(t)
+	defer c.Close()
+	payload := []byte("a\r\nb\x00\xff")
+	_, err := c.Do("SET", "conn:binary", payload)
+	assert.Nil(t, err)
+	value, err := Bytes(c.Do("GET", "conn:binary"))
+	assert.Nil(t, err)
+	assert.Equal(t, payload, value)
+	n, err := Int(c.Do("APPEND", "conn:binary", 42))
+	assert.Nil(t, err)
+	assert.Equal(t, len(payload)+2, n)
+}
+
+func TestConnDoErrorReply(t *testing.T) {
+	c := dial(t)
+	defer c.Close()
+	_, err := c.Do("NOSUCHCOMMAND")
+	_, ok := err.(resp.RedisError)
+	assert.True(t, ok, "Error replies must be returned as resp.RedisError")
+	assert.Nil(t, c.Err(), "Error replies must leave the connection usable")
+	_, err = c.Do("SET", "conn:error", "1")
+	assert.Nil(t, err)
+}
+
+func TestConnPipeline(t *testing.T) {
+	c := dial(t)
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, c.Send("SET", "conn:pipeline", i))
+	}
+	assert.Nil(t, c.Send("GET", "conn:pipeline"))
+	assert.Nil(t, c.Flush())
+	for i := 0; i < 100; i++ {
+		_, err := c.Receive()
+		assert.Nil(t, err)
+	}
+	n, err := Int(c.Receive())
+	assert.Nil(t, err)
+	assert.Equal(t, 99, n)
+}
+
+func TestConnDoDiscardsPendingReplies(t *testing.T) {
+	c := dial(t)
+	defer c.Close()
+	assert.Nil(t, c.Send("SET", "conn:pending", "a"))
+	assert.Nil(t, c.Send("NOSUCHCOMMAND"))
+	value, err := String(c.Do("GET", "conn:pending"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a", value)
+}
+
+func TestConnHello(t *testing.T) {
+	c := dial(t)
+	defer c.Close()
+	m, err := StringMap(c.Do("HELLO", 3))
+	assert.Nil(t, err)
+	assert.Equal(t, "3", m["proto"])
+	_, err = String(c.Do("GET", "conn:missing"))
+	assert.Equal(t, ErrNil, err, "The RESP3 null must decode as nil")
+}
+
+func TestConnClosed(t *testing.T) {
+	c := dial(t)
+	c.Close()
+	_, err := c.Do("GET", "foo")
+	assert.NotNil(t, err)
+	assert.NotNil(t, c.Err())
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"errors"
+	"golang-redis-mock/resp"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Get once the pool has been closed
+var ErrPoolClosed = errors.New("client: pool closed")
+
+// Pool keeps idle connections around for reuse. It is safe for concurrent use.
+type Pool struct {
+	dial    func() (*Conn, error)
+	maxIdle int
+
+	mu     sync.Mutex
+	idle   []*Conn
+	closed bool
+}
+
+// NewPool creates a pool that opens connections with dial and keeps at most
+// maxIdle of them open while they are not in use
+func NewPool(dial func() (*Conn, error), maxIdle int) *Pool {
+	return &Pool{
+		dial:    dial,
+		maxIdle: maxIdle,
+		idle:    make([]*Conn, 0, maxIdle),
+	}
+}
+
+// Get returns an idle connection, or dials a new one if there is none. The
+// connection must be handed back with Put once the caller is done with it.
+func (p *Pool) Get() (*Conn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	if n := len(p.idle); n > 0 {
+		c := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return c, nil
+	}
+	p.mu.Unlock()
+	return p.dial()
+}
+
+// Put hands a connection back to the pool. Broken connections, connections
+// with replies still pending and connections beyond maxIdle are closed.
+func (p *Pool) Put(c *Conn) {
+	if c.err != nil {
+		return
+	}
+	p.mu.Lock()
+	if p.closed || c.pending > 0 || len(p.idle) >= p.maxIdle {
+		p.mu.Unlock()
+		c.Close()
+		return
+	}
+	p.idle = append(p.idle, c)
+	p.mu.Unlock()
+}
+
+// Do runs a single command on a pooled connection
+func (p *Pool) Do(cmd string, args ...interface{}) (resp.IDataType, error) {
+	c, err := p.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer p.Put(c)
+	return c.Do(cmd, args...)
+}
+
+// Close closes all idle connections. Connections in use are closed when they
+// are put back.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	var err error
+	for _, c := range idle {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolReusesConnections(t *testing.T) {
+	address := startServer(t).Addr()
+	dials := 0
+	p := NewPool(func() (*Conn, error) {
+		dials++
+		return Dial("tcp", address)
+	}, 2)
+	defer p.Close()
+	for i := 0; i < 10; i++ {
+		_, err := p.Do("SET", "pool:reuse", i)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 1, dials, "Idle connections must be reused")
+}
+
+func TestPoolDropsBrokenConnections(t *testing.T) {
+	address := startServer(t).Addr()
+	p := NewPool(func() (*Conn, error) {
+		return Dial("tcp", address)
+	}, 2)
+	defer p.Close()
+	c, err := p.Get()
+	assert.Nil(t, err)
+	c.Close()
+	p.Put(c)
+	c2, err := p.Get()
+	assert.Nil(t, err)
+	assert.True(t, c != c2, "Closed connections must not be handed out again")
+	p.Put(c2)
+}
+
+func TestPoolConcurrentUse(t *testing.T) {
+	address := startServer(t).Addr()
+	p := NewPool(func() (*Conn, error) {
+		return Dial("tcp", address)
+	}, 4)
+	defer p.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "pool:concurrent:" + strconv.Itoa(i)
+			_, err := p.Do("SET", key, i)
+			assert.Nil(t, err)
+			n, err := Int(p.Do("GET", key))
+			assert.Nil(t, err)
+			assert.Equal(t, i, n)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestPoolClosed(t *testing.T) {
+	p := NewPool(func() (*Conn, error) {
+		return Dial("tcp", startServer(t).Addr())
+	}, 1)
+	p.Close()
+	_, err := p.Get()
+	assert.Equal(t, ErrPoolClosed, err)
+}
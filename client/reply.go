@@ -0,0 +1,243 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"golang-redis-mock/resp"
+	"strconv"
+)
+
+// Helpers that decode a reply into a Go value. They take the results of Do
+// or Receive as is, so calls can be chained:
+//
+//	n, err := client.Int(c.Do("STRLEN", "foo"))
+
+// ErrNil is returned when the reply is a null bulk string, null array or the
+// RESP3 null, such as GET on a missing key
+var ErrNil = errors.New("client: nil reply")
+
+// Drop attributes, which only describe the reply, and report nulls
+func unwrap(reply resp.IDataType, err error) (resp.IDataType, error) {
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := reply.(resp.Attribute); ok {
+		reply = a.GetValue()
+	}
+	// Aggregates built by hand are usually pointers
+	switch v := reply.(type) {
+	case *resp.Array:
+		reply = *v
+	case *resp.Map:
+		reply = *v
+	case *resp.Set:
+		reply = *v
+	case *resp.Push:
+		reply = *v
+	}
+	switch v := reply.(type) {
+	case nil, resp.Null:
+		return nil, ErrNil
+	case resp.BulkString:
+		if v.IsNull() {
+			return nil, ErrNil
+		}
+	case resp.Array:
+		if v.IsNull() {
+			return nil, ErrNil
+		}
+	}
+	return reply, nil
+}
+
+// Build the error for a reply of unexpected type
+func unexpectedType(target string, reply resp.IDataType) error {
+	return fmt.Errorf("client: cannot convert reply of type %T to %s", reply, target)
+}
+
+// Bytes decodes a simple, bulk or verbatim string reply into raw bytes
+func Bytes(reply resp.IDataType, err error) ([]byte, error) {
+	reply, err = unwrap(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	switch v := reply.(type) {
+	case resp.BulkString:
+		return v.Bytes(), nil
+	case resp.VerbatimString:
+		return v.Bytes(), nil
+	case resp.String:
+		return []byte(v.ToString()), nil
+	}
+	return nil, unexpectedType("[]byte", reply)
+}
+
+// String decodes a string reply. Integers and doubles are formatted as well
+func String(reply resp.IDataType, err error) (string, error) {
+	reply, err = unwrap(reply, err)
+	if err != nil {
+		return "", err
+	}
+	switch reply.(type) {
+	case resp.BulkString, resp.VerbatimString, resp.String, resp.Integer, resp.Double, resp.BigNumber:
+		return reply.ToString(), nil
+	}
+	return "", unexpectedType("string", reply)
+}
+
+// Int decodes an integer reply, or a string reply holding an integer
+func Int(reply resp.IDataType, err error) (int, error) {
+	n, err := Int64(reply, err)
+	if err != nil {
+		return 0, err
+	}
+	if int64(int(n)) != n {
+		return 0, fmt.Errorf("client: integer reply %d overflows int", n)
+	}
+	return int(n), nil
+}
+
+// Int64 decodes an integer reply, or a string reply holding an integer, with
+// the full 64 bit range of Redis integers
+func Int64(reply resp.IDataType, err error) (int64, error) {
+	reply, err = unwrap(reply, err)
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case resp.Integer:
+		return v.GetIntegerValue(), nil
+	case resp.BulkString, resp.String:
+		return strconv.ParseInt(v.ToString(), 10, 64)
+	}
+	return 0, unexpectedType("int64", reply)
+}
+
+// Float64 decodes a double reply, or a string reply holding a number
+func Float64(reply resp.IDataType, err error) (float64, error) {
+	reply, err = unwrap(reply, err)
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case resp.Double:
+		return v.GetDoubleValue(), nil
+	case resp.Integer:
+		return float64(v.GetIntegerValue()), nil
+	case resp.BulkString, resp.String:
+		return strconv.ParseFloat(v.ToString(), 64)
+	}
+	return 0, unexpectedType("float64", reply)
+}
+
+// Bool decodes a boolean reply. RESP2 servers reply with 1 or 0 instead
+func Bool(reply resp.IDataType, err error) (bool, error) {
+	reply, err = unwrap(reply, err)
+	if err != nil {
+		return false, err
+	}
+	switch v := reply.(type) {
+	case resp.Boolean:
+		return v.GetBooleanValue(), nil
+	case resp.Integer:
+		return v.GetIntegerValue() != 0, nil
+	case resp.BulkString, resp.String:
+		return strconv.ParseBool(v.ToString())
+	}
+	return false, unexpectedType("bool", reply)
+}
+
+// Values decodes an array, set or push reply into its items
+func Values(reply resp.IDataType, err error) ([]resp.IDataType, error) {
+	reply, err = unwrap(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	switch v := reply.(type) {
+	case resp.Array:
+		values := make([]resp.IDataType, v.GetNumberOfItems())
+		for i := range values {
+			values[i] = v.GetItemAtIndex(i)
+		}
+		return values, nil
+	case resp.Set:
+		values := make([]resp.IDataType, v.GetNumberOfItems())
+		for i := range values {
+			values[i] = v.GetItemAtIndex(i)
+		}
+		return values, nil
+	case resp.Push:
+		values := make([]resp.IDataType, v.GetNumberOfItems())
+		for i := range values {
+			values[i] = v.GetItemAtIndex(i)
+		}
+		return values, nil
+	}
+	return nil, unexpectedType("[]resp.IDataType", reply)
+}
+
+// Strings decodes an array of strings, such as the reply of KEYS. Null items
+// become empty strings
+func Strings(reply resp.IDataType, err error) ([]string, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(values))
+	for i, value := range values {
+		str, err := String(value, nil)
+		if err != nil && err != ErrNil {
+			return nil, err
+		}
+		strs[i] = str
+	}
+	return strs, nil
+}
+
+// StringMap decodes a map reply with string keys and values, such as the
+// reply of HELLO or CONFIG GET. RESP2 servers send maps as flat arrays of
+// alternating keys and values, which are understood as well
+func StringMap(reply resp.IDataType, err error) (map[string]string, error) {
+	reply, err = unwrap(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string)
+	switch v := reply.(type) {
+	case resp.Map:
+		for i := 0; i < v.GetNumberOfPairs(); i++ {
+			key, value := v.GetPairAtIndex(i)
+			if err := addStringPair(m, key, value); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case resp.Array:
+		if v.GetNumberOfItems()%2 != 0 {
+			return nil, errors.New("client: map reply has an odd number of items")
+		}
+		for i := 0; i < v.GetNumberOfItems(); i += 2 {
+			if err := addStringPair(m, v.GetItemAtIndex(i), v.GetItemAtIndex(i+1)); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, unexpectedType("map[string]string", reply)
+}
+
+// Add a key and value to m, aggregates are kept by their representation
+func addStringPair(m map[string]string, key resp.IDataType, value resp.IDataType) error {
+	k, err := String(key, nil)
+	if err != nil {
+		return err
+	}
+	v, err := String(value, nil)
+	if err == ErrNil {
+		v = ""
+	} else if err != nil {
+		v = value.ToString()
+	}
+	m[k] = v
+	return nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"errors"
+	"golang-redis-mock/resp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bulk(s string) resp.BulkString {
+	bs, _ := resp.NewBulkString(s)
+	return bs
+}
+
+func TestReplyNil(t *testing.T) {
+	for _, reply := range []resp.IDataType{resp.NewNullBulkString(), *resp.NewNullArray(), resp.NewNull()} {
+		_, err := String(reply, nil)
+		assert.Equal(t, ErrNil, err, reply)
+	}
+}
+
+func TestReplyPassesErrors(t *testing.T) {
+	e := errors.New("boom")
+	_, err := Int(nil, e)
+	assert.Equal(t, e, err)
+}
+
+func TestReplyScalars(t *testing.T) {
+	s, err := String(resp.NewString("OK"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", s)
+	b, err := Bytes(bulk("a\x00b"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a\x00b"), b)
+	n, err := Int(resp.NewInteger(-3), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, -3, n)
+	n, err = Int(bulk("12"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, n)
+	f, err := Float64(resp.NewDouble(1.5), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, f)
+	ok, err := Bool(resp.NewInteger(1), nil)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = Bool(resp.NewBoolean(false), nil)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	_, err = Int(resp.NewMap(), nil)
+	assert.NotNil(t, err, "Aggregates cannot be decoded as scalars")
+}
+
+func TestReplyAttributeIsDropped(t *testing.T) {
+	s, err := String(resp.NewAttribute(resp.NewMap(), bulk("v")), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "v", s)
+}
+
+func TestReplyStrings(t *testing.T) {
+	ra, _ := resp.NewArray(3)
+	ra.SetItemAtIndex(0, bulk("a"))
+	ra.SetItemAtIndex(1, resp.NewNullBulkString())
+	ra.SetItemAtIndex(2, resp.NewInteger(1))
+	strs, err := Strings(*ra, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "", "1"}, strs)
+}
+
+func TestReplyStringMap(t *testing.T) {
+	m := resp.NewMap()
+	m.Add(resp.NewString("proto"), resp.NewInteger(3))
+	m.Add(resp.NewString("mode"), resp.NewString("standalone"))
+	decoded, err := StringMap(*m, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"proto": "3", "mode": "standalone"}, decoded)
+
+	// RESP2 sends the same map as a flat array
+	ra, _ := resp.NewArray(2)
+	ra.SetItemAtIndex(0, bulk("maxclients"))
+	ra.SetItemAtIndex(1, bulk("10000"))
+	decoded, err = StringMap(*ra, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"maxclients": "10000"}, decoded)
+
+	odd, _ := resp.NewArray(1)
+	odd.SetItemAtIndex(0, bulk("k"))
+	_, err = StringMap(*odd, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,170 @@
+// Command redis-mock-cli is a minimal redis-cli. It runs the command given as
+// arguments, or reads commands from stdin when there is none.
+//
+//	redis-mock-cli -h localhost -p 6382 SET foo bar
+//	redis-mock-cli -s /tmp/redis.sock GET foo
+//	redis-mock-cli -p 6390 --tls --cacert ca.crt --cert client.crt --key client.key
+//	redis-mock-cli -a secret GET foo
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"golang-redis-mock/client"
+	"golang-redis-mock/resp"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	host := flag.String("h", "localhost", "Server hostname")
+	port := flag.Int("p", 6382, "Server port")
+	socket := flag.String("s", "", "Server socket, overrides hostname and port")
+	useTLS := flag.Bool("tls", false, "Establish a secure TLS connection")
+	cacert := flag.String("cacert", "", "CA certificate file to verify the server with")
+	cert := flag.String("cert", "", "Client certificate to authenticate with")
+	key := flag.String("key", "", "Private key file to authenticate with")
+	password := flag.String("a", "", "Password to use when connecting to the server")
+	user := flag.String("user", "", "Used to send ACL style 'AUTH username pass'. Needs -a")
+	flag.Parse()
+
+	network, address := "tcp", net.JoinHostPort(*host, strconv.Itoa(*port))
+	if *socket != "" {
+		network, address = "unix", *socket
+	}
+	// connect to this socket
+	var conn *client.Conn
+	var err error
+	if *useTLS {
+		var tlsConfig *tls.Config
+		tlsConfig, err = newTLSConfig(*cacert, *cert, *key)
+		if err == nil {
+			conn, err = client.DialTLS(network, address, tlsConfig)
+		}
+	} else {
+		conn, err = client.Dial(network, address)
+	}
+	if err != nil {
+		fmt.Println("Error connecting:", err.Error())
+		os.Exit(1)
+	}
+	defer conn.Close()
+	if *password != "" {
+		auth := []interface{}{*password}
+		if *user != "" {
+			auth = []interface{}{*user, *password}
+		}
+		if _, err := conn.Do("AUTH", auth...); err != nil {
+			fmt.Println("AUTH failed:", err.Error())
+			os.Exit(1)
+		}
+	}
+	if flag.NArg() > 0 {
+		if !runCommand(conn, flag.Args()) {
+			os.Exit(1)
+		}
+		return
+	}
+	prompt := address + "> "
+	// read in input from stdin
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print(prompt)
+		text, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args, ok := resp.SplitArgs(text)
+		if !ok {
+			fmt.Println("Invalid argument(s)")
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if !runCommand(conn, args) {
+			return
+		}
+	}
+}
+
+// Build the TLS configuration from the certificate files given as flags
+func newTLSConfig(cacert string, cert string, key string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if cacert != "" {
+		pem, err := ioutil.ReadFile(cacert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cacert)
+		}
+	}
+	if cert != "" {
+		certificate, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// Send a command to the server and print the reply. Returns false if the
+// connection failed
+func runCommand(conn *client.Conn, parts []string) bool {
+	args := make([]interface{}, len(parts)-1)
+	for i, part := range parts[1:] {
+		args[i] = part
+	}
+	// send to socket and listen for reply
+	reply, err := conn.Do(parts[0], args...)
+	if e, ok := err.(resp.RedisError); ok {
+		fmt.Println("(error) " + e.ToString())
+		return true
+	}
+	if err != nil {
+		fmt.Println("Error reading reply:", err.Error())
+		return false
+	}
+	fmt.Println(formatReply(reply))
+	return true
+}
+
+// formatReply formats a reply the way redis-cli does
+func formatReply(reply resp.IDataType) string {
+	switch v := reply.(type) {
+	case resp.String:
+		return v.ToString()
+	case resp.RedisError:
+		return "(error) " + v.ToString()
+	case resp.Integer:
+		return "(integer) " + v.ToString()
+	case resp.BulkString:
+		if v.IsNull() {
+			return "(nil)"
+		}
+		return fmt.Sprintf("%q", v.Bytes())
+	case resp.Array:
+		if v.IsNull() {
+			return "(nil)"
+		}
+		if v.GetNumberOfItems() == 0 {
+			return "(empty array)"
+		}
+		items := make([]string, v.GetNumberOfItems())
+		for i := range items {
+			items[i] = fmt.Sprintf("%d) %s", i+1, formatReply(v.GetItemAtIndex(i)))
+		}
+		return strings.Join(items, "\n")
+	default:
+		return reply.ToString()
+	}
+}
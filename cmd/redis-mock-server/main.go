@@ -0,0 +1,43 @@
+// Command redis-mock-server serves the mock until SHUTDOWN, SIGINT or SIGTERM
+// stops it. It is configured like redis-server:
+//
+//	redis-mock-server [/path/to/redis.conf] [--port 6382] [--bind localhost] ...
+package main
+
+import (
+	"fmt"
+	"golang-redis-mock/commands"
+	"golang-redis-mock/config"
+	"golang-redis-mock/server"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	cfg, err := config.Parse(os.Args[1:])
+	if err != nil {
+		fmt.Println("Error in configuration:", err.Error())
+		os.Exit(1)
+	}
+	// SIGINT and SIGTERM shut down the same way SHUTDOWN does. They are
+	// caught before the server is ready, so that they never kill it
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	s := server.New(server.Options{Config: cfg})
+	if err := s.Start(); err != nil {
+		fmt.Println("Error listening:", err.Error())
+		os.Exit(1)
+	}
+	// With port 0 the system picks the port, so print the actual address
+	for _, addr := range s.Addrs() {
+		fmt.Println("Listening on " + addr)
+	}
+	go func() {
+		sig := <-signals
+		fmt.Printf("Received %s, scheduling shutdown...\n", sig)
+		s.Shutdown(commands.ShutdownOptions{})
+	}()
+	s.Wait()
+	fmt.Println("Redis mock is now ready to exit, bye bye...")
+}
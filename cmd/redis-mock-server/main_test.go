@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"golang-redis-mock/client"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The test binary runs main in place of the tests when this is set, so that
+// the server can be signalled as a process of its own
+const runMainEnv = "REDIS_MOCK_SERVER_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func TestShutdownSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGTERM cannot be sent on windows")
+	}
+	cmd := exec.Command(os.Args[0], "--port", "0", "--bind", "127.0.0.1")
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	out := bufio.NewReader(stdout)
+	line, err := out.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := strings.TrimPrefix(strings.TrimSpace(line), "Listening on ")
+
+	c, err := client.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.Do("SET", "signal", "1")
+	assert.Nil(t, err)
+
+	assert.Nil(t, cmd.Process.Signal(syscall.SIGTERM))
+	var rest []byte
+	exited := make(chan error, 1)
+	go func() {
+		// The output must be read before waiting for the process
+		rest, _ = ioutil.ReadAll(out)
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		assert.Nil(t, err, "The server must exit cleanly")
+	case <-time.After(5 * time.Second):
+		t.Fatal("The server did not exit after SIGTERM")
+	}
+	assert.Contains(t, string(rest), "Received terminated, scheduling shutdown...")
+	assert.Contains(t, string(rest), "Redis mock is now ready to exit, bye bye...")
+	_, err = c.Do("GET", "signal")
+	assert.NotNil(t, err, "Connections must be closed by the shutdown")
+}
@@ -0,0 +1,189 @@
+package commands
+
+// ACL from https://redis.io/commands#server, and the permissions of the
+// commands it controls
+
+import (
+	"fmt"
+	"golang-redis-mock/acl"
+	"golang-redis-mock/resp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const aclCommand = "ACL"
+
+// Every command with the categories and keys the permission checks use,
+// taken from the command table of Redis
+var commandSpecs = []acl.CommandSpec{
+	{Name: "get", Categories: []string{"read", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "set", Categories: []string{"write", "string", "slow"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "getset", Categories: []string{"write", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "del", Categories: []string{"keyspace", "write", "slow"}, FirstKey: 1, LastKey: -1, KeyStep: 1},
+	{Name: "strlen", Categories: []string{"read", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "append", Categories: []string{"write", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "setnx", Categories: []string{"write", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "setex", Categories: []string{"write", "string", "slow"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "incr", Categories: []string{"write", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "decr", Categories: []string{"write", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "incrby", Categories: []string{"write", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "decrby", Categories: []string{"write", "string", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+	{Name: "hello", Categories: []string{"fast", "connection"}, NoAuth: true},
+	{Name: "auth", Categories: []string{"fast", "connection"}, NoAuth: true},
+	{Name: "ping", Categories: []string{"fast", "connection"}},
+	{Name: "config", Categories: []string{"admin", "slow", "dangerous"}},
+	{Name: "info", Categories: []string{"slow", "dangerous"}},
+	{Name: "shutdown", Categories: []string{"admin", "slow", "dangerous"}},
+	{Name: "acl", Categories: []string{"admin", "slow", "dangerous"}},
+	{Name: "client", Categories: []string{"admin", "slow", "dangerous", "connection"}},
+}
+
+// aclLogEntry describes a denial in reply to ACL LOG
+type aclLogEntry struct {
+	Count                int64   `resp:"count"`
+	Reason               string  `resp:"reason"`
+	Context              string  `resp:"context"`
+	Object               string  `resp:"object"`
+	Username             string  `resp:"username"`
+	AgeSeconds           float64 `resp:"age-seconds"`
+	ClientInfo           string  `resp:"client-info"`
+	EntryID              int64   `resp:"entry-id"`
+	TimestampCreated     int64   `resp:"timestamp-created"`
+	TimestampLastUpdated int64   `resp:"timestamp-last-updated"`
+}
+
+// The arguments of a command as strings, the command included
+func arguments(ra *resp.Array) []string {
+	args := make([]string, ra.GetNumberOfItems())
+	for i := range args {
+		args[i] = ra.GetItemAtIndex(i).ToString()
+	}
+	return args
+}
+
+// Check whether the user of the connection may run the command args, and
+// record the denial in the ACL log if it may not
+func checkPermissions(s *Session, args []string) resp.RedisError {
+	spec, ok := s.state.users.Command(args[0])
+	if !ok {
+		// Unknown commands fail when they are run
+		return resp.EmptyRedisError
+	}
+	if d := s.state.users.Check(s.user, spec, args); d != nil {
+		s.logDenial(d)
+		return resp.NewRedisError("NOPERM", d.Error())
+	}
+	return resp.EmptyRedisError
+}
+
+// execute ACL SETUSER|GETUSER|DELUSER|USERS|LIST|WHOAMI|CAT|DRYRUN|LOG
+func executeACLCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	numberOfItems := ra.GetNumberOfItems()
+	if numberOfItems < 2 {
+		return nil, resp.NewDefaultRedisError("wrong number of arguments for (acl) command")
+	}
+	args := arguments(ra)
+	users := s.state.users
+	switch subcommand := strings.ToUpper(args[1]); {
+	case subcommand == "SETUSER" && numberOfItems > 2:
+		if e := users.SetUser(args[2], args[3:]...); e != nil {
+			return nil, resp.NewDefaultRedisError(e.Error())
+		}
+		return redisOk, resp.EmptyRedisError
+	case subcommand == "GETUSER" && numberOfItems == 3:
+		info, ok := users.Info(args[2])
+		if !ok {
+			return resp.EmptyBulkString, resp.EmptyRedisError
+		}
+		reply, _ := resp.Marshal(info)
+		return reply, resp.EmptyRedisError
+	case subcommand == "DELUSER" && numberOfItems > 2:
+		deleted, e := users.DeleteUsers(args[2:]...)
+		if e != nil {
+			return nil, resp.NewDefaultRedisError(e.Error())
+		}
+		return resp.NewInteger(int64(deleted)), resp.EmptyRedisError
+	case subcommand == "USERS" && numberOfItems == 2:
+		reply, _ := resp.Marshal(users.Names())
+		return reply, resp.EmptyRedisError
+	case subcommand == "LIST" && numberOfItems == 2:
+		var rules []string
+		for _, name := range users.Names() {
+			if description, ok := users.Describe(name); ok {
+				rules = append(rules, description)
+			}
+		}
+		reply, _ := resp.Marshal(rules)
+		return reply, resp.EmptyRedisError
+	case subcommand == "WHOAMI" && numberOfItems == 2:
+		return newBulkString(s.user.Name()), resp.EmptyRedisError
+	case subcommand == "CAT" && numberOfItems <= 3:
+		if numberOfItems == 2 {
+			reply, _ := resp.Marshal(acl.Categories)
+			return reply, resp.EmptyRedisError
+		}
+		names, ok := users.Commands(strings.ToLower(args[2]))
+		if !ok {
+			return nil, resp.NewDefaultRedisError(fmt.Sprintf("Unknown category '%s'", args[2]))
+		}
+		reply, _ := resp.Marshal(names)
+		return reply, resp.EmptyRedisError
+	case subcommand == "DRYRUN" && numberOfItems > 3:
+		return executeACLDryRunCommand(s, args[2], args[3:])
+	case subcommand == "LOG" && numberOfItems <= 3:
+		return executeACLLogCommand(s, args[2:])
+	}
+	return nil, resp.NewDefaultRedisError(fmt.Sprintf("Unknown subcommand or wrong number of arguments for '%s'. Try ACL HELP.", args[1]))
+}
+
+// execute ACL DRYRUN username command [arg ...], which tells whether the
+// user could run the command without running it
+func executeACLDryRunCommand(s *Session, username string, args []string) (resp.IDataType, resp.RedisError) {
+	user, ok := s.state.users.Lookup(username)
+	if !ok {
+		return nil, resp.NewDefaultRedisError(fmt.Sprintf("User '%s' not found", username))
+	}
+	spec, ok := s.state.users.Command(args[0])
+	if !ok {
+		return nil, resp.NewDefaultRedisError(fmt.Sprintf("Command '%s' not found", args[0]))
+	}
+	if d := s.state.users.Check(user, spec, args); d != nil {
+		return newBulkString(d.Verbose()), resp.EmptyRedisError
+	}
+	return redisOk, resp.EmptyRedisError
+}
+
+// execute ACL LOG [count|RESET], which lists the latest denials
+func executeACLLogCommand(s *Session, args []string) (resp.IDataType, resp.RedisError) {
+	count := -1
+	if len(args) == 1 {
+		if strings.ToUpper(args[0]) == "RESET" {
+			s.state.aclLog.Reset()
+			return redisOk, resp.EmptyRedisError
+		}
+		n, e := strconv.Atoi(args[0])
+		if e != nil || n < 0 {
+			return nil, resp.NewDefaultRedisError("value is out of range, must be positive")
+		}
+		count = n
+	}
+	now := time.Now()
+	entries := []aclLogEntry{}
+	for _, e := range s.state.aclLog.Entries(count) {
+		entries = append(entries, aclLogEntry{
+			Count:                e.Count,
+			Reason:               e.Reason,
+			Context:              e.Context,
+			Object:               e.Object,
+			Username:             e.Username,
+			AgeSeconds:           now.Sub(e.Created).Seconds(),
+			ClientInfo:           e.ClientInfo,
+			EntryID:              e.ID,
+			TimestampCreated:     e.Created.UnixNano() / int64(time.Millisecond),
+			TimestampLastUpdated: e.Updated.UnixNano() / int64(time.Millisecond),
+		})
+	}
+	reply, _ := resp.Marshal(entries)
+	return reply, resp.EmptyRedisError
+}
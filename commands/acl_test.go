@@ -0,0 +1,72 @@
+package commands_test
+
+import (
+	"golang-redis-mock/client"
+	"golang-redis-mock/resp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACL(t *testing.T) {
+	s := startServer(t, nil)
+	admin := dial(t, s)
+	_, err := admin.Do("ACL", "SETUSER", "reader", "on", ">secret", "~cache:*", "+@read")
+	assert.Nil(t, err)
+	_, err = admin.Do("SET", "cache:1", "v")
+	assert.Nil(t, err)
+	_, err = admin.Do("ACL", "SETUSER", "reader", "+nosuchcommand")
+	assert.Equal(t, "ERR Error in ACL SETUSER modifier '+nosuchcommand': Unknown command or category name in ACL", err.Error())
+	users, err := client.Strings(admin.Do("ACL", "LIST"))
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "user default on nopass ~* &* +@all", users[0])
+
+	c := dial(t, s)
+	_, err = c.Do("AUTH", "reader", "secret")
+	assert.Nil(t, err)
+	_, err = c.Do("ACL", "WHOAMI")
+	assert.Equal(t, "NOPERM User reader has no permissions to run the 'acl' command", err.Error())
+	user, _ := client.String(admin.Do("ACL", "WHOAMI"))
+	assert.Equal(t, "default", user)
+	value, err := client.String(c.Do("GET", "cache:1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "v", value)
+	_, err = c.Do("SET", "cache:1", "w")
+	assert.Equal(t, "NOPERM User reader has no permissions to run the 'set' command", err.Error())
+	_, err = c.Do("GET", "other")
+	assert.Equal(t, "NOPERM No permissions to access a key", err.Error())
+
+	reply, err := client.String(admin.Do("ACL", "DRYRUN", "reader", "GET", "other"))
+	assert.Nil(t, err)
+	assert.Equal(t, "No permissions to access the 'other' key", reply)
+	reply, err = client.String(admin.Do("ACL", "DRYRUN", "reader", "GET", "cache:2"))
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", reply)
+	commands, err := client.Strings(admin.Do("ACL", "CAT", "read"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"get", "strlen"}, commands)
+
+	var entries []map[string]interface{}
+	log, err := admin.Do("ACL", "LOG")
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Unmarshal(log, &entries))
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "key", entries[0]["reason"])
+	assert.Equal(t, "other", entries[0]["object"])
+	assert.Equal(t, "command", entries[1]["reason"])
+	assert.Equal(t, "set", entries[1]["object"])
+	assert.Equal(t, "acl", entries[2]["object"])
+	_, err = admin.Do("ACL", "LOG", "RESET")
+	assert.Nil(t, err)
+
+	n, err := client.Int(admin.Do("ACL", "DELUSER", "reader"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	_, err = c.Do("GET", "cache:1")
+	assert.NotNil(t, err)
+	_, isReply := err.(resp.RedisError)
+	assert.False(t, isReply, "Connections of deleted users must be closed")
+	_, err = admin.Do("ACL", "DELUSER", "default")
+	assert.Equal(t, "ERR The 'default' user cannot be removed", err.Error())
+}
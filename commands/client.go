@@ -0,0 +1,306 @@
+package commands
+
+// CLIENT from https://redis.io/commands#connection, which lists and manages
+// the clients connected to the server
+
+import (
+	"fmt"
+	"golang-redis-mock/config"
+	"golang-redis-mock/resp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const clientCommand = "CLIENT"
+
+// Commands whose first argument is a subcommand, reported as command|subcommand
+// by CLIENT LIST
+var containerCommands = map[string]bool{"acl": true, "client": true, "config": true}
+
+// Client types of CLIENT LIST TYPE and CLIENT KILL TYPE
+var clientTypes = map[string]bool{"normal": true, "master": true, "replica": true, "slave": true, "pubsub": true}
+
+var errClientName = resp.NewDefaultRedisError("Client names cannot contain spaces, newlines or special characters.")
+
+// Register a connected client
+func (st *State) addClient(s *Session) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.clients[s.id] = s
+}
+
+// Forget a client that disconnected
+func (st *State) removeClient(s *Session) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.clients, s.id)
+}
+
+// The connected clients, oldest first
+func (st *State) sessions() []*Session {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sessions := make([]*Session, 0, len(st.clients))
+	for _, s := range st.clients {
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].id < sessions[j].id })
+	return sessions
+}
+
+// Record the command the client runs, for CLIENT LIST
+func (s *Session) recordCommand(ra *resp.Array) {
+	command := strings.ToLower(ra.GetItemAtIndex(0).ToString())
+	if containerCommands[command] && ra.GetNumberOfItems() > 1 {
+		command += "|" + strings.ToLower(ra.GetItemAtIndex(1).ToString())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCommand = command
+	s.lastInteraction = time.Now()
+}
+
+// Client names and library names may only hold printable characters other
+// than spaces
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// The addresses of the client and of the server it connected to. Like in
+// Redis, unix socket clients show the path of the socket as both
+func (s *Session) addrs() (string, string, bool) {
+	local := s.conn.LocalAddr()
+	if local.Network() == "unix" {
+		return local.String() + ":0", local.String() + ":0", true
+	}
+	return s.conn.RemoteAddr().String(), local.String(), false
+}
+
+// Class returns the client class whose client-output-buffer-limit applies to
+// the connection. Every client is a normal one until there are replicas and
+// pub/sub, so the limits of the other classes are accepted but never apply
+func (s *Session) Class() string {
+	return config.ClientNormal
+}
+
+// Describe the client in the format of CLIENT LIST
+func (s *Session) info() string {
+	addr, laddr, unix := s.addrs()
+	flags := "N"
+	if unix {
+		flags = "U"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Replies are queued whole, there is no fixed size buffer like in Redis
+	var replies int
+	var size int64
+	if s.output != nil {
+		replies, size = s.output.Pending()
+	}
+	now := time.Now()
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=0 sub=0 psub=0 multi=-1 qbuf=%d obl=0 oll=%d omem=%d cmd=%s user=%s resp=%d lib-name=%s lib-ver=%s",
+		s.id, addr, laddr, s.name,
+		int64(now.Sub(s.created)/time.Second), int64(now.Sub(s.lastInteraction)/time.Second),
+		flags, s.queryBuffer, replies, size, s.lastCommand, s.user.Name(), s.protocol, s.libName, s.libVersion)
+}
+
+// Close the connection of the client. A client killing itself still gets
+// the reply
+func (s *Session) kill(by *Session) {
+	if s == by {
+		s.closeAfterReply = true
+		return
+	}
+	s.conn.Close()
+}
+
+// execute CLIENT ID|SETNAME|GETNAME|LIST|INFO|KILL|SETINFO
+func executeClientCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	numberOfItems := ra.GetNumberOfItems()
+	if numberOfItems < 2 {
+		return nil, resp.NewDefaultRedisError("wrong number of arguments for (client) command")
+	}
+	args := arguments(ra)
+	switch subcommand := strings.ToUpper(args[1]); {
+	case subcommand == "ID" && numberOfItems == 2:
+		return resp.NewInteger(s.id), resp.EmptyRedisError
+	case subcommand == "SETNAME" && numberOfItems == 3:
+		if !validClientName(args[2]) {
+			return nil, errClientName
+		}
+		s.mu.Lock()
+		s.name = args[2]
+		s.mu.Unlock()
+		return redisOk, resp.EmptyRedisError
+	case subcommand == "GETNAME" && numberOfItems == 2:
+		s.mu.Lock()
+		name := s.name
+		s.mu.Unlock()
+		if name == "" {
+			return resp.EmptyBulkString, resp.EmptyRedisError
+		}
+		return newBulkString(name), resp.EmptyRedisError
+	case subcommand == "LIST":
+		return executeClientListCommand(s, args[2:])
+	case subcommand == "INFO" && numberOfItems == 2:
+		return newBulkString(s.info() + "\n"), resp.EmptyRedisError
+	case subcommand == "KILL" && numberOfItems > 2:
+		return executeClientKillCommand(s, args[2:])
+	case subcommand == "SETINFO" && numberOfItems == 4:
+		return executeClientSetInfoCommand(s, args[2], args[3])
+	}
+	return nil, resp.NewDefaultRedisError(fmt.Sprintf("Unknown subcommand or wrong number of arguments for '%s'. Try CLIENT HELP.", args[1]))
+}
+
+// execute CLIENT LIST [TYPE type] [ID id [id ...]]
+func executeClientListCommand(s *Session, args []string) (resp.IDataType, resp.RedisError) {
+	clientType := ""
+	var ids map[int64]bool
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "TYPE" && i+1 < len(args):
+			clientType = strings.ToLower(args[i+1])
+			if !clientTypes[clientType] {
+				return nil, resp.NewDefaultRedisError(fmt.Sprintf("Unknown client type '%s'", args[i+1]))
+			}
+			i++
+		case option == "ID" && i+1 < len(args):
+			ids = map[int64]bool{}
+			for i++; i < len(args); i++ {
+				id, e := strconv.ParseInt(args[i], 10, 64)
+				if e != nil || id <= 0 {
+					return nil, resp.NewDefaultRedisError("Invalid client ID")
+				}
+				ids[id] = true
+			}
+		default:
+			return nil, resp.NewDefaultRedisError("syntax error")
+		}
+	}
+	var b strings.Builder
+	for _, c := range s.state.sessions() {
+		if clientType != "" && clientType != "normal" {
+			continue
+		}
+		if ids != nil && !ids[c.id] {
+			continue
+		}
+		b.WriteString(c.info() + "\n")
+	}
+	return newBulkString(b.String()), resp.EmptyRedisError
+}
+
+// execute CLIENT KILL addr, or CLIENT KILL with filters: ID, TYPE, USER,
+// ADDR, LADDR, SKIPME and MAXAGE. The first form replies OK, the second one
+// with the number of clients killed
+func executeClientKillCommand(s *Session, args []string) (resp.IDataType, resp.RedisError) {
+	if len(args) == 1 {
+		for _, c := range s.state.sessions() {
+			if addr, _, _ := c.addrs(); addr == args[0] {
+				c.kill(s)
+				return redisOk, resp.EmptyRedisError
+			}
+		}
+		return nil, resp.NewDefaultRedisError("No such client")
+	}
+	if len(args)%2 != 0 {
+		return nil, resp.NewDefaultRedisError("syntax error")
+	}
+	var id, maxAge int64
+	var clientType, user, addr, laddr string
+	skipMe := true
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "ID":
+			n, e := strconv.ParseInt(value, 10, 64)
+			if e != nil || n <= 0 {
+				return nil, resp.NewDefaultRedisError("client-id should be greater than 0")
+			}
+			id = n
+		case "TYPE":
+			clientType = strings.ToLower(value)
+			if !clientTypes[clientType] {
+				return nil, resp.NewDefaultRedisError(fmt.Sprintf("Unknown client type '%s'", value))
+			}
+		case "USER":
+			if _, ok := s.state.users.Lookup(value); !ok {
+				return nil, resp.NewDefaultRedisError(fmt.Sprintf("No such user '%s'", value))
+			}
+			user = value
+		case "ADDR":
+			addr = value
+		case "LADDR":
+			laddr = value
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return nil, resp.NewDefaultRedisError("syntax error")
+			}
+		case "MAXAGE":
+			n, e := strconv.ParseInt(value, 10, 64)
+			if e != nil {
+				return nil, errNotInteger
+			}
+			maxAge = n
+		default:
+			return nil, resp.NewDefaultRedisError("syntax error")
+		}
+	}
+	killed := int64(0)
+	for _, c := range s.state.sessions() {
+		cAddr, cLaddr, _ := c.addrs()
+		switch {
+		case id != 0 && c.id != id,
+			clientType != "" && clientType != "normal",
+			user != "" && c.userName() != user,
+			addr != "" && cAddr != addr,
+			laddr != "" && cLaddr != laddr,
+			skipMe && c == s,
+			maxAge != 0 && int64(time.Since(c.created)/time.Second) < maxAge:
+			continue
+		}
+		c.kill(s)
+		killed++
+	}
+	return resp.NewInteger(killed), resp.EmptyRedisError
+}
+
+// The name of the user the client runs commands as
+func (s *Session) userName() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.user.Name()
+}
+
+// execute CLIENT SETINFO LIB-NAME|LIB-VER value, which client libraries use
+// to tell who they are
+func executeClientSetInfoCommand(s *Session, option string, value string) (resp.IDataType, resp.RedisError) {
+	option = strings.ToLower(option)
+	if option != "lib-name" && option != "lib-ver" {
+		return nil, resp.NewDefaultRedisError(fmt.Sprintf("Unrecognized option '%s'", option))
+	}
+	if !validClientName(value) {
+		return nil, resp.NewDefaultRedisError(fmt.Sprintf("%s cannot contain spaces, newlines or special characters.", option))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if option == "lib-name" {
+		s.libName = value
+	} else {
+		s.libVersion = value
+	}
+	return redisOk, resp.EmptyRedisError
+}
@@ -0,0 +1,71 @@
+package commands_test
+
+import (
+	"fmt"
+	"golang-redis-mock/client"
+	"golang-redis-mock/resp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	s := startServer(t, nil)
+	c := dial(t, s)
+	id, err := client.Int64(c.Do("CLIENT", "ID"))
+	assert.Nil(t, err)
+	_, err = client.String(c.Do("CLIENT", "GETNAME"))
+	assert.Equal(t, client.ErrNil, err)
+	_, err = c.Do("CLIENT", "SETNAME", "has space")
+	assert.Equal(t, "ERR Client names cannot contain spaces, newlines or special characters.", err.Error())
+	_, err = c.Do("CLIENT", "SETNAME", "worker-1")
+	assert.Nil(t, err)
+	name, err := client.String(c.Do("CLIENT", "GETNAME"))
+	assert.Nil(t, err)
+	assert.Equal(t, "worker-1", name)
+	_, err = c.Do("CLIENT", "SETINFO", "LIB-NAME", "go-mock")
+	assert.Nil(t, err)
+	_, err = c.Do("CLIENT", "SETINFO", "LIB-COLOR", "red")
+	assert.Equal(t, "ERR Unrecognized option 'lib-color'", err.Error())
+	info, err := client.String(c.Do("CLIENT", "INFO"))
+	assert.Nil(t, err)
+	assert.Contains(t, info, fmt.Sprintf("id=%d addr=", id))
+	assert.Contains(t, info, " name=worker-1 ")
+	assert.Contains(t, info, " cmd=client|info user=default resp=2 lib-name=go-mock lib-ver=\n")
+
+	other := dial(t, s)
+	m, err := client.StringMap(other.Do("HELLO", 3, "SETNAME", "worker-2"))
+	assert.Nil(t, err)
+	otherID, _ := strconv.ParseInt(m["id"], 10, 64)
+	list, err := client.String(c.Do("CLIENT", "LIST"))
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSuffix(list, "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "name=worker-1")
+	assert.Contains(t, lines[1], "name=worker-2")
+	assert.Contains(t, lines[1], "cmd=hello")
+	list, err = client.String(c.Do("CLIENT", "LIST", "ID", otherID))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(list, "\n"))
+	stats, _ := client.String(c.Do("INFO", "clients"))
+	assert.Contains(t, stats, "connected_clients:2\r\n")
+
+	killed, err := client.Int(c.Do("CLIENT", "KILL", "ID", otherID))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, killed)
+	_, err = other.Do("PING")
+	_, isReply := err.(resp.RedisError)
+	assert.False(t, isReply, "Killed clients must be disconnected")
+	killed, err = client.Int(c.Do("CLIENT", "KILL", "USER", "default"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, killed, "Clients must not kill themselves unless SKIPME is no")
+	_, err = c.Do("CLIENT", "KILL", "127.0.0.1:1")
+	assert.Equal(t, "ERR No such client", err.Error())
+
+	_, err = c.Do("CLIENT", "KILL", "ID", id, "SKIPME", "no")
+	assert.Nil(t, err, "Clients killing themselves must get the reply")
+	_, err = c.Do("CLIENT", "ID")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,35 @@
+package commands_test
+
+import (
+	"golang-redis-mock/client"
+	"golang-redis-mock/config"
+	"golang-redis-mock/server"
+	"testing"
+)
+
+// Commands are tested the way clients see them, through a server
+
+// startServer starts a server configured by cfg, or the defaults if cfg is
+// nil, on a random local port. It is closed when the test ends
+func startServer(t *testing.T, cfg *config.Config) *server.Server {
+	if cfg != nil {
+		cfg.Bind = []string{"127.0.0.1"}
+		cfg.Port = 0
+	}
+	s := server.New(server.Options{Config: cfg})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// dial connects to s. The connection is closed when the test ends
+func dial(t *testing.T, s *server.Server) *client.Conn {
+	c, err := client.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
@@ -0,0 +1,168 @@
+package commands
+
+// CONFIG and INFO from https://redis.io/commands#server, which inspect and
+// change the server at runtime
+
+import (
+	"fmt"
+	"golang-redis-mock/config"
+	"golang-redis-mock/glob"
+	"golang-redis-mock/resp"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	configCommand = "CONFIG"
+	infoCommand   = "INFO"
+)
+
+// execute CONFIG GET|SET|RESETSTAT|REWRITE
+func executeConfigCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	numberOfItems := ra.GetNumberOfItems()
+	if numberOfItems < 2 {
+		return nil, resp.NewDefaultRedisError("wrong number of arguments for (config) command")
+	}
+	subcommand := strings.ToUpper(ra.GetItemAtIndex(1).ToString())
+	switch {
+	case subcommand == "GET" && numberOfItems > 2:
+		return executeConfigGetCommand(s, ra)
+	case subcommand == "SET" && numberOfItems > 3 && numberOfItems%2 == 0:
+		return executeConfigSetCommand(s, ra)
+	case subcommand == "RESETSTAT" && numberOfItems == 2:
+		atomic.StoreInt64(&s.state.connectionsReceived, 0)
+		atomic.StoreInt64(&s.state.connectionsRejected, 0)
+		atomic.StoreInt64(&s.state.outputLimitDisconnections, 0)
+		atomic.StoreInt64(&s.state.commandsProcessed, 0)
+		return redisOk, resp.EmptyRedisError
+	case subcommand == "REWRITE" && numberOfItems == 2:
+		if e := s.state.config.Load().Rewrite(); e != nil {
+			return nil, resp.NewDefaultRedisError(e.Error())
+		}
+		return redisOk, resp.EmptyRedisError
+	}
+	return nil, resp.NewDefaultRedisError(fmt.Sprintf("Unknown subcommand or wrong number of arguments for '%s'. Try CONFIG HELP.", ra.GetItemAtIndex(1).ToString()))
+}
+
+// execute CONFIG GET pattern [pattern ...], which replies with the name and
+// value of every parameter matching any of the patterns
+func executeConfigGetCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	c := s.state.config.Load()
+	reply := resp.NewMap()
+	for _, name := range config.Parameters() {
+		for i := 2; i < ra.GetNumberOfItems(); i++ {
+			if glob.MatchNoCase(ra.GetItemAtIndex(i).ToString(), name) {
+				value, _ := c.Get(name)
+				reply.Add(newBulkString(name), newBulkString(value))
+				break
+			}
+		}
+	}
+	return reply, resp.EmptyRedisError
+}
+
+// execute CONFIG SET parameter value [parameter value ...]. Either all
+// parameters are changed, or none of them
+func executeConfigSetCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	err := resp.EmptyRedisError
+	requirepass := s.state.config.Load().RequirePass
+	s.state.config.Update(func(c *config.Config) error {
+		for i := 2; i < ra.GetNumberOfItems(); i += 2 {
+			name := ra.GetItemAtIndex(i).ToString()
+			value := ra.GetItemAtIndex(i + 1).ToString()
+			if _, ok := c.Get(name); !ok {
+				err = resp.NewDefaultRedisError(fmt.Sprintf("Unknown option or number of arguments for CONFIG SET - '%s'", name))
+				return err
+			}
+			if !config.IsMutable(name) {
+				err = resp.NewDefaultRedisError(fmt.Sprintf("CONFIG SET failed (possibly related to argument '%s') - can't set immutable config", name))
+				return err
+			}
+			// Like Redis, only values of several arguments are split, others
+			// are taken as they are, spaces and quotes included
+			args := []string{value}
+			if config.IsMultiArg(name) {
+				if split, ok := resp.SplitArgs(value); ok && len(split) > 0 {
+					args = split
+				}
+			}
+			if e := c.Set(name, args...); e != nil {
+				err = resp.NewDefaultRedisError(fmt.Sprintf("CONFIG SET failed (possibly related to argument '%s') - %s", name, e.Error()))
+				return err
+			}
+		}
+		return nil
+	})
+	if err != resp.EmptyRedisError {
+		return nil, err
+	}
+	// requirepass is the password of the default user
+	if c := s.state.config.Load(); c.RequirePass != requirepass {
+		s.state.users.SetDefaultPassword(c.RequirePass)
+	}
+	return redisOk, resp.EmptyRedisError
+}
+
+// execute INFO [section], which describes the server in the text format
+// Redis uses
+func executeInfoCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	section := "default"
+	if ra.GetNumberOfItems() > 2 {
+		return nil, resp.NewDefaultRedisError("syntax error")
+	}
+	if ra.GetNumberOfItems() == 2 {
+		section = strings.ToLower(ra.GetItemAtIndex(1).ToString())
+	}
+	c := s.state.config.Load()
+	sections := []struct {
+		name   string
+		fields map[string]interface{}
+	}{
+		{"server", map[string]interface{}{
+			"redis_version":     serverVersion,
+			"redis_mode":        "standalone",
+			"process_id":        os.Getpid(),
+			"tcp_port":          atomic.LoadInt64(&s.state.tcpPort),
+			"uptime_in_seconds": int64(time.Since(s.state.startTime) / time.Second),
+			"config_file":       c.File,
+		}},
+		{"clients", map[string]interface{}{
+			"connected_clients": len(s.state.sessions()),
+			"maxclients":        c.MaxClients,
+		}},
+		{"stats", map[string]interface{}{
+			"total_connections_received":                atomic.LoadInt64(&s.state.connectionsReceived),
+			"rejected_connections":                      atomic.LoadInt64(&s.state.connectionsRejected),
+			"total_commands_processed":                  atomic.LoadInt64(&s.state.commandsProcessed),
+			"client_output_buffer_limit_disconnections": atomic.LoadInt64(&s.state.outputLimitDisconnections),
+		}},
+	}
+	var b strings.Builder
+	for _, sec := range sections {
+		if section != "all" && section != "default" && section != "everything" && section != sec.name {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.Title(sec.name) + "\r\n")
+		names := make([]string, 0, len(sec.fields))
+		for name := range sec.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s:%v\r\n", name, sec.fields[name])
+		}
+	}
+	return newBulkString(b.String()), resp.EmptyRedisError
+}
+
+// Make a bulk string reply from a string that is known to fit
+func newBulkString(s string) resp.BulkString {
+	bs, _ := resp.NewBulkString(s)
+	return bs
+}
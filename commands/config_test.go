@@ -0,0 +1,78 @@
+package commands_test
+
+import (
+	"golang-redis-mock/client"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	c := dial(t, startServer(t, nil))
+	m, err := client.StringMap(c.Do("CONFIG", "GET", "P*", "timeout"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"proto-max-bulk-len": "1048576", "timeout": "0", "port": "0"}, m)
+
+	_, err = c.Do("CONFIG", "SET", "timeout", "30", "proto-max-bulk-len", "2mb")
+	assert.Nil(t, err)
+	m, err = client.StringMap(c.Do("CONFIG", "GET", "timeout", "proto-max-bulk-len"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"proto-max-bulk-len": "2097152", "timeout": "30"}, m)
+	_, err = c.Do("SET", "config", make([]byte, 2*1024*1024))
+	assert.Nil(t, err, "proto-max-bulk-len must take effect")
+
+	_, err = c.Do("CONFIG", "SET", "timeout", "0", "proto-max-bulk-len", "1k")
+	assert.Equal(t, "ERR CONFIG SET failed (possibly related to argument 'proto-max-bulk-len') - argument must be between 1048576 and 536870912 inclusive", err.Error())
+	timeout, _ := client.StringMap(c.Do("CONFIG", "GET", "timeout"))
+	assert.Equal(t, "30", timeout["timeout"], "A failed CONFIG SET must not change anything")
+	_, err = c.Do("CONFIG", "SET", "port", "7000")
+	assert.Equal(t, "ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config", err.Error())
+	_, err = c.Do("CONFIG", "SET", "nosuchparameter", "1")
+	assert.Equal(t, "ERR Unknown option or number of arguments for CONFIG SET - 'nosuchparameter'", err.Error())
+	_, err = c.Do("CONFIG", "SET", "timeout", "0")
+	assert.Nil(t, err)
+
+	_, err = c.Do("CONFIG", "REWRITE")
+	assert.Equal(t, "ERR The server is running without a config file", err.Error())
+	_, err = c.Do("CONFIG", "RESETSTAT")
+	assert.Nil(t, err)
+	info, err := client.String(c.Do("INFO", "stats"))
+	assert.Nil(t, err)
+	assert.Contains(t, info, "total_commands_processed:1\r\n")
+}
+
+func TestConfigSetVerbatim(t *testing.T) {
+	s := startServer(t, nil)
+	c := dial(t, s)
+	_, err := c.Do("CONFIG", "SET", "requirepass", "a b")
+	assert.Nil(t, err, "Values of one argument must not be split")
+	m, err := client.StringMap(c.Do("CONFIG", "GET", "requirepass"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a b", m["requirepass"])
+	_, err = c.Do("CONFIG", "SET", "requirepass", `"x"`)
+	assert.Nil(t, err)
+	other := dial(t, s)
+	_, err = other.Do("AUTH", "x")
+	assert.NotNil(t, err, "Quotes must be part of the password")
+	_, err = other.Do("AUTH", `"x"`)
+	assert.Nil(t, err)
+
+	_, err = c.Do("CONFIG", "SET", "client-output-buffer-limit", "normal 1mb 256kb 10")
+	assert.Nil(t, err, "Values of several arguments must be split")
+	m, err = client.StringMap(c.Do("CONFIG", "GET", "client-output-buffer-limit"))
+	assert.Nil(t, err)
+	assert.Contains(t, m["client-output-buffer-limit"], "normal 1048576 262144 10")
+}
+
+func TestInfo(t *testing.T) {
+	s := startServer(t, nil)
+	c := dial(t, s)
+	info, err := client.String(c.Do("INFO", "server"))
+	assert.Nil(t, err)
+	_, port, _ := net.SplitHostPort(s.Addr())
+	assert.Contains(t, info, "tcp_port:"+port+"\r\n", "The port picked for port 0 must be reported")
+	assert.NotContains(t, info, "# Clients")
+	_, err = c.Do("INFO", "server", "clients")
+	assert.Equal(t, "ERR syntax error", err.Error())
+}
@@ -0,0 +1,301 @@
+package commands
+
+// Connection level commands from https://redis.io/commands#connection. Unlike
+// the string commands, these act on the state of the client's connection.
+
+import (
+	"fmt"
+	"golang-redis-mock/acl"
+	"golang-redis-mock/resp"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	helloCommand = "HELLO"
+	authCommand  = "AUTH"
+	pingCommand  = "PING"
+)
+
+// Errors Redis replies with to unauthenticated connections and wrong
+// credentials
+var (
+	errNoAuth    = resp.NewRedisError("NOAUTH", "Authentication required.")
+	errWrongPass = resp.NewRedisError("WRONGPASS", "invalid username-password pair or user is disabled.")
+)
+
+// Reported by HELLO. Clients may check the version to decide which features
+// they can use, so we claim to be a recent Redis
+const (
+	serverName    = "redis"
+	serverVersion = "6.0.0"
+)
+
+// Session ids are unique for the lifetime of the process
+var lastSessionID int64
+
+// Session holds the state of a single client connection
+type Session struct {
+	id       int64
+	protocol int
+	// State of the server the client is connected to
+	state *State
+	// The connection of the client, which CLIENT KILL closes
+	conn    net.Conn
+	created time.Time
+	// Set once the connection should be closed instead of replying
+	closing bool
+	// Set once the connection should be closed after replying
+	closeAfterReply bool
+	// Whether the client may run commands other than AUTH and HELLO
+	authenticated bool
+	// The user the commands run as
+	user *acl.User
+
+	// Guards what other connections read with CLIENT LIST, along with
+	// protocol and user. The connection itself reads them without locking
+	mu              sync.Mutex
+	name            string
+	libName         string
+	libVersion      string
+	lastCommand     string
+	lastInteraction time.Time
+	queryBuffer     int
+	output          OutputBuffer
+}
+
+// OutputBuffer holds the replies waiting to be sent to a client
+type OutputBuffer interface {
+	// Pending returns the number of replies and bytes not sent yet
+	Pending() (int, int64)
+}
+
+// NewSession creates the state for a new connection conn to the server
+// described by state, and registers it as a client until Close is called.
+// Connections start out speaking RESP2 as the default user, and are
+// authenticated unless the default user has a password
+func NewSession(state *State, conn net.Conn) *Session {
+	atomic.AddInt64(&state.connectionsReceived, 1)
+	user, _ := state.users.Lookup(acl.DefaultUser)
+	now := time.Now()
+	s := &Session{
+		id:              atomic.AddInt64(&lastSessionID, 1),
+		protocol:        resp.RESP2,
+		state:           state,
+		conn:            conn,
+		created:         now,
+		authenticated:   state.users.NoPass(acl.DefaultUser),
+		user:            user,
+		lastInteraction: now,
+		lastCommand:     "NULL",
+	}
+	state.addClient(s)
+	return s
+}
+
+// Close removes the connection from the clients of the server. The
+// connection itself is closed by whoever opened it
+func (s *Session) Close() {
+	s.state.removeClient(s)
+}
+
+// Protocol returns the RESP version negotiated by the connection
+func (s *Session) Protocol() int {
+	return s.protocol
+}
+
+// Closing reports whether the last command asked to close the connection, in
+// which case its reply must not be sent
+func (s *Session) Closing() bool {
+	return s.closing
+}
+
+// CloseAfterReply reports whether the connection must be closed once the
+// reply to the last command is sent
+func (s *Session) CloseAfterReply() bool {
+	return s.closeAfterReply
+}
+
+// SetQueryBuffer records how many bytes were received but not run yet, for
+// CLIENT LIST
+func (s *Session) SetQueryBuffer(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queryBuffer = n
+}
+
+// SetOutputBuffer records where the replies to the client wait to be sent,
+// for CLIENT LIST
+func (s *Session) SetOutputBuffer(output OutputBuffer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.output = output
+}
+
+// helloReply describes the server in reply to HELLO
+type helloReply struct {
+	Server  string   `resp:"server"`
+	Version string   `resp:"version"`
+	Proto   int      `resp:"proto"`
+	ID      int64    `resp:"id"`
+	Mode    string   `resp:"mode"`
+	Role    string   `resp:"role"`
+	Modules []string `resp:"modules"`
+}
+
+// Record a denial in the ACL log
+func (s *Session) logDenial(d *acl.Denial) {
+	s.state.aclLog.Add(d, "toplevel", s.info(), s.state.config.Load().ACLLogMaxLen)
+}
+
+// Authenticate the connection as username. Users with nopass, such as the
+// default user without requirepass, take any password
+func (s *Session) authenticate(username string, password string) resp.RedisError {
+	user, ok := s.state.users.Authenticate(username, password)
+	if !ok {
+		s.logDenial(&acl.Denial{Reason: acl.ReasonAuth, Object: "AUTH", Username: username})
+		return errWrongPass
+	}
+	s.mu.Lock()
+	s.user = user
+	s.mu.Unlock()
+	s.authenticated = true
+	return resp.EmptyRedisError
+}
+
+// execute PING [message], which health checks use to tell whether the server
+// is up
+func executePingCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	switch ra.GetNumberOfItems() {
+	case 1:
+		return resp.NewString("PONG"), resp.EmptyRedisError
+	case 2:
+		return newBulkString(ra.GetItemAtIndex(1).ToString()), resp.EmptyRedisError
+	}
+	return nil, resp.NewDefaultRedisError("wrong number of arguments for (ping) command")
+}
+
+// execute AUTH [username] password
+func executeAuthCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	numberOfItems := ra.GetNumberOfItems()
+	if numberOfItems < 2 || numberOfItems > 3 {
+		return nil, resp.NewDefaultRedisError("wrong number of arguments for (auth) command")
+	}
+	if numberOfItems == 2 {
+		if s.state.users.NoPass(acl.DefaultUser) {
+			return nil, resp.NewDefaultRedisError("AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		}
+		if err := s.authenticate(acl.DefaultUser, ra.GetItemAtIndex(1).ToString()); err != resp.EmptyRedisError {
+			return nil, err
+		}
+		return redisOk, resp.EmptyRedisError
+	}
+	if err := s.authenticate(ra.GetItemAtIndex(1).ToString(), ra.GetItemAtIndex(2).ToString()); err != resp.EmptyRedisError {
+		return nil, err
+	}
+	return redisOk, resp.EmptyRedisError
+}
+
+// execute HELLO [protover [AUTH username password] [SETNAME clientname]],
+// which switches the protocol of the connection and replies with a map
+// describing the server
+func executeHelloCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	numberOfItems := ra.GetNumberOfItems()
+	protocol := s.protocol
+	if numberOfItems > 1 {
+		version, e := strconv.Atoi(ra.GetItemAtIndex(1).ToString())
+		if e != nil {
+			return nil, resp.NewDefaultRedisError("Protocol version is not an integer or out of range")
+		}
+		if version != resp.RESP2 && version != resp.RESP3 {
+			return nil, resp.NewRedisError("NOPROTO", "unsupported protocol version")
+		}
+		protocol = version
+	}
+	name := ""
+	for i := 2; i < numberOfItems; i++ {
+		option := ra.GetItemAtIndex(i).ToString()
+		switch {
+		case strings.ToUpper(option) == authCommand && i+2 < numberOfItems:
+			if err := s.authenticate(ra.GetItemAtIndex(i+1).ToString(), ra.GetItemAtIndex(i+2).ToString()); err != resp.EmptyRedisError {
+				return nil, err
+			}
+			i += 2
+		case strings.ToUpper(option) == "SETNAME" && i+1 < numberOfItems:
+			name = ra.GetItemAtIndex(i + 1).ToString()
+			if !validClientName(name) {
+				return nil, errClientName
+			}
+			i++
+		default:
+			return nil, resp.NewDefaultRedisError(fmt.Sprintf("Syntax error in HELLO option '%s'", option))
+		}
+	}
+	if !s.authenticated {
+		return nil, resp.NewRedisError("NOAUTH", "HELLO must be called with the client already authenticated, otherwise the HELLO AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+	s.mu.Lock()
+	s.protocol = protocol
+	if name != "" {
+		s.name = name
+	}
+	s.mu.Unlock()
+	reply, _ := resp.Marshal(helloReply{
+		Server:  serverName,
+		Version: serverVersion,
+		Proto:   protocol,
+		ID:      s.id,
+		Mode:    "standalone",
+		Role:    "master",
+		Modules: []string{},
+	})
+	return reply, resp.EmptyRedisError
+}
+
+// ExecuteCommand runs a command on behalf of the connection described by s,
+// if its user may. Connection and server level commands are handled here,
+// everything else is passed on to ExecuteStringCommand
+func ExecuteCommand(s *Session, ra resp.Array) (resp.IDataType, resp.RedisError) {
+	if ra.GetNumberOfItems() == 0 {
+		return nil, resp.NewDefaultRedisError("No command found")
+	}
+	// Like Redis, connections of deleted users are closed
+	if s.state.users.Deleted(s.user) {
+		s.closing = true
+		return nil, resp.EmptyRedisError
+	}
+	atomic.AddInt64(&s.state.commandsProcessed, 1)
+	s.recordCommand(&ra)
+	command := strings.ToUpper(ra.GetItemAtIndex(0).ToString())
+	if !s.authenticated && command != authCommand && command != helloCommand {
+		return nil, errNoAuth
+	}
+	if err := checkPermissions(s, arguments(&ra)); err != resp.EmptyRedisError {
+		return nil, err
+	}
+	switch command {
+	case authCommand:
+		return executeAuthCommand(s, &ra)
+	case helloCommand:
+		return executeHelloCommand(s, &ra)
+	case pingCommand:
+		return executePingCommand(s, &ra)
+	case configCommand:
+		return executeConfigCommand(s, &ra)
+	case infoCommand:
+		return executeInfoCommand(s, &ra)
+	case shutdownCommand:
+		return executeShutdownCommand(s, &ra)
+	case aclCommand:
+		return executeACLCommand(s, &ra)
+	case clientCommand:
+		return executeClientCommand(s, &ra)
+	default:
+		break
+	}
+	return ExecuteStringCommand(s.state.keyspace, ra)
+}
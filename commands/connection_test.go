@@ -0,0 +1,68 @@
+package commands_test
+
+import (
+	"golang-redis-mock/client"
+	"golang-redis-mock/resp"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	s := startServer(t, nil)
+	c := dial(t, s)
+	reply, err := c.Do("PING")
+	assert.Nil(t, err)
+	assert.Equal(t, resp.NewString("PONG"), reply)
+	message, err := client.String(c.Do("PING", "hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", message)
+	_, err = c.Do("PING", "a", "b")
+	assert.Equal(t, "ERR wrong number of arguments for (ping) command", err.Error())
+
+	// Health checks send it inline, as typed into netcat
+	conn, err := net.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+	conn.Write([]byte("ping\r\nset foo bar\n"))
+	r := resp.NewReader(conn)
+	for _, expected := range []string{"PONG", "OK"} {
+		reply, err = r.ReadValue()
+		assert.Nil(t, err)
+		assert.Equal(t, resp.NewString(expected), reply)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	s := startServer(t, nil)
+	c := dial(t, s)
+	_, err := c.Do("AUTH", "secret")
+	assert.Contains(t, err.Error(), "called without any password configured")
+	_, err = c.Do("CONFIG", "SET", "requirepass", "secret")
+	assert.Nil(t, err)
+	_, err = c.Do("SET", "auth", "1")
+	assert.Nil(t, err, "Connections must stay authenticated when requirepass changes")
+
+	other := dial(t, s)
+	_, err = other.Do("GET", "auth")
+	assert.Equal(t, "NOAUTH Authentication required.", err.Error())
+	_, err = other.Do("HELLO", 3)
+	assert.Contains(t, err.Error(), "NOAUTH HELLO must be called with the client already authenticated")
+	_, err = other.Do("AUTH", "wrong")
+	assert.Equal(t, "WRONGPASS invalid username-password pair or user is disabled.", err.Error())
+	_, err = other.Do("AUTH", "alice", "secret")
+	assert.Equal(t, "WRONGPASS invalid username-password pair or user is disabled.", err.Error())
+	_, err = other.Do("AUTH", "secret")
+	assert.Nil(t, err)
+	value, err := client.String(other.Do("GET", "auth"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1", value)
+
+	hello := dial(t, s)
+	m, err := client.StringMap(hello.Do("HELLO", 3, "AUTH", "default", "secret"))
+	assert.Nil(t, err)
+	assert.Equal(t, "3", m["proto"])
+	_, err = hello.Do("GET", "auth")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,147 @@
+package commands
+
+// SHUTDOWN from https://redis.io/commands#server, and the state every
+// connection to a server shares
+
+import (
+	"golang-redis-mock/acl"
+	"golang-redis-mock/config"
+	"golang-redis-mock/resp"
+	"golang-redis-mock/storage"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const shutdownCommand = "SHUTDOWN"
+
+// State is shared by all connections to one server
+type State struct {
+	// Configuration of the server
+	config *config.Store
+	// Keys and their values
+	keyspace *storage.GenericConcurrentMap
+	// Users and their permissions, and the latest denials
+	users  *acl.Users
+	aclLog acl.Log
+
+	// Counters reported by INFO, until CONFIG RESETSTAT clears them
+	connectionsReceived       int64
+	connectionsRejected       int64
+	outputLimitDisconnections int64
+	commandsProcessed         int64
+	startTime                 time.Time
+	// Port the server actually listens on, which port 0 leaves to the system
+	tcpPort int64
+
+	mu sync.Mutex
+	// Stops the server, set by whoever runs it
+	shutdown func(options ShutdownOptions) error
+	// Connected clients by id
+	clients map[int64]*Session
+}
+
+// NewState creates the state of a server configured by cfg, with an empty
+// keyspace. The default user is the only user, with requirepass as its
+// password
+func NewState(cfg *config.Store) *State {
+	st := &State{
+		config:    cfg,
+		keyspace:  storage.NewGenericConcurrentMap(),
+		users:     acl.NewUsers(commandSpecs),
+		startTime: time.Now(),
+		clients:   map[int64]*Session{},
+	}
+	st.users.SetDefaultPassword(cfg.Load().RequirePass)
+	return st
+}
+
+// LoadACLFile loads the users of the aclfile parameter, if it is set
+func (st *State) LoadACLFile() error {
+	path := st.config.Load().ACLFile
+	if path == "" {
+		return nil
+	}
+	return st.users.LoadFile(path)
+}
+
+// Keyspace returns the keys and values the commands work on
+func (st *State) Keyspace() *storage.GenericConcurrentMap {
+	return st.keyspace
+}
+
+// RejectConnection counts a connection refused because maxclients were
+// connected already, for INFO
+func (st *State) RejectConnection() {
+	atomic.AddInt64(&st.connectionsRejected, 1)
+}
+
+// DisconnectClient counts a client disconnected for going beyond its
+// client-output-buffer-limit, for INFO
+func (st *State) DisconnectClient() {
+	atomic.AddInt64(&st.outputLimitDisconnections, 1)
+}
+
+// SetTCPPort sets the port INFO reports, once the server listens on it
+func (st *State) SetTCPPort(port int) {
+	atomic.StoreInt64(&st.tcpPort, int64(port))
+}
+
+// Close releases the keyspace once the server is done with it
+func (st *State) Close() {
+	st.keyspace.Close()
+}
+
+// ShutdownOptions are the arguments of SHUTDOWN
+type ShutdownOptions struct {
+	// Save or do not save the data before exiting. The mock keeps everything
+	// in memory, so both only matter to persistence added later
+	Save   bool
+	NoSave bool
+	// Do not wait for commands in flight to finish
+	Now bool
+}
+
+// HandleShutdown sets the function SHUTDOWN calls to stop the server. It
+// should start the shutdown and return without waiting for it, since the
+// connection running SHUTDOWN is one of those it waits for. Without a
+// handler, SHUTDOWN fails.
+func (st *State) HandleShutdown(handler func(options ShutdownOptions) error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.shutdown = handler
+}
+
+// execute SHUTDOWN [NOSAVE|SAVE] [NOW]. A shutdown cannot be aborted once
+// started, so ABORT is a syntax error like any other option. When the shutdown starts the
+// connection is closed without a reply, like Redis does
+func executeShutdownCommand(s *Session, ra *resp.Array) (resp.IDataType, resp.RedisError) {
+	var options ShutdownOptions
+	for i := 1; i < ra.GetNumberOfItems(); i++ {
+		switch strings.ToUpper(ra.GetItemAtIndex(i).ToString()) {
+		case "SAVE":
+			options.Save = true
+		case "NOSAVE":
+			options.NoSave = true
+		case "NOW":
+			options.Now = true
+		default:
+			return nil, resp.NewDefaultRedisError("syntax error")
+		}
+	}
+	if options.Save && options.NoSave {
+		return nil, resp.NewDefaultRedisError("syntax error")
+	}
+	s.state.mu.Lock()
+	handler := s.state.shutdown
+	s.state.mu.Unlock()
+	if handler == nil {
+		return nil, resp.NewDefaultRedisError("Errors trying to SHUTDOWN. Check logs.")
+	}
+	if e := handler(options); e != nil {
+		return nil, resp.NewDefaultRedisError(e.Error())
+	}
+	s.closing = true
+	return nil, resp.EmptyRedisError
+}
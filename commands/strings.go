@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"golang-redis-mock/resp"
 	"golang-redis-mock/storage"
+	"math"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -18,13 +20,24 @@ const (
 	appendCommand       = "APPEND"
 	setnxCommand        = "SETNX"
 	setAndExpireCommand = "SETEX"
+	incrCommand         = "INCR"
+	decrCommand         = "DECR"
+	incrByCommand       = "INCRBY"
+	decrByCommand       = "DECRBY"
 )
 
-var gm = storage.NewGenericConcurrentMap()
 var redisOk = resp.NewString("OK")
 
+// Errors Redis replies with when integer arguments or values do not fit in
+// a signed 64 bit integer
+var (
+	errNotInteger   = resp.NewDefaultRedisError("value is not an integer or out of range")
+	errIncrOverflow = resp.NewDefaultRedisError("increment or decrement would overflow")
+	errDecrOverflow = resp.NewDefaultRedisError("decrement would overflow")
+)
+
 // execute a get command on concurrent map and return the result
-func executeGetCommand(ra *resp.Array) (resp.IDataType, resp.RedisError) {
+func executeGetCommand(gm *storage.GenericConcurrentMap, ra *resp.Array) (resp.IDataType, resp.RedisError) {
 	// 	// Get argument takes only a single key name.
 	numberOfItems := ra.GetNumberOfItems()
 	if numberOfItems == 1 {
@@ -43,7 +56,7 @@ func executeGetCommand(ra *resp.Array) (resp.IDataType, resp.RedisError) {
 		// If we cannot find it, we return Nil bulk string
 		return resp.EmptyBulkString, resp.EmptyRedisError
 	}
-	bs, e := resp.NewBulkString(value)
+	bs, e := resp.NewBulkStringFromBytes(value)
 	if e != nil {
 		return nil, resp.NewDefaultRedisError(e.Error())
 	}
@@ -76,9 +89,21 @@ func getGuardedKey(key resp.IDataType) (string, resp.RedisError) {
 	}
 }
 
+// Get the raw bytes of a value. Bulk strings are taken as is so that binary
+// values survive, other types are stored by their string representation.
+// Bulk strings are copied, as they point into the connection's read buffer
+func getValueBytes(value resp.IDataType) []byte {
+	switch v := value.(type) {
+	case resp.BulkString:
+		return append([]byte{}, v.Bytes()...)
+	default:
+		return []byte(value.ToString())
+	}
+}
+
 // execute a set command on concurrent map. If returnPreviousKey is set to true, then it returns
 // the previous set value as first return value
-func executeSetCommand(ra *resp.Array, returnPreviousKey bool, onlyIfKeyExists bool) (resp.IDataType, resp.RedisError) {
+func executeSetCommand(gm *storage.GenericConcurrentMap, ra *resp.Array, returnPreviousKey bool, onlyIfKeyExists bool) (resp.IDataType, resp.RedisError) {
 	// 	// Get argument takes only a single key name.
 	numberOfItems := ra.GetNumberOfItems()
 	if numberOfItems == 2 {
@@ -99,21 +124,21 @@ func executeSetCommand(ra *resp.Array, returnPreviousKey bool, onlyIfKeyExists b
 		_, ok := gm.Load(key)
 		if ok != true {
 			// Key does not exist, return
-			gm.Store(key, value.ToString())
+			gm.Store(key, getValueBytes(value))
 			return resp.NewInteger(1), resp.EmptyRedisError
 		} else {
 			return resp.NewInteger(0), resp.EmptyRedisError
 		}
 	}
-	gm.Store(key, value.ToString())
+	gm.Store(key, getValueBytes(value))
 	return getStoreCommandReply(value, returnPreviousKey)
 }
 
 // Delete a key from storage, and return number of keys removed
-func executeDeleteCommand(ra *resp.Array) (resp.Integer, resp.RedisError) {
+func executeDeleteCommand(gm *storage.GenericConcurrentMap, ra *resp.Array) (resp.Integer, resp.RedisError) {
 	// Get number of items
 	numberOfItems := ra.GetNumberOfItems()
-	numberOfKeysDeleted := 0
+	var numberOfKeysDeleted int64
 	if numberOfItems == 1 {
 		return resp.EmptyInteger, resp.NewDefaultRedisError("wrong number of arguments for (del) command")
 	}
@@ -131,28 +156,31 @@ func executeDeleteCommand(ra *resp.Array) (resp.Integer, resp.RedisError) {
 }
 
 // Append target to a key's value if it exists and return the length of new value
-func executeAppendCommand(ra *resp.Array) (resp.Integer, resp.RedisError) {
+func executeAppendCommand(gm *storage.GenericConcurrentMap, ra *resp.Array) (resp.Integer, resp.RedisError) {
 	numberOfItems := ra.GetNumberOfItems()
-	if numberOfItems < 2 {
+	if numberOfItems != 3 {
 		return resp.EmptyInteger, resp.NewDefaultRedisError("wrong number of arguments for (append) command")
 	}
 	key, err := getGuardedKey(ra.GetItemAtIndex(1))
 	if err != resp.EmptyRedisError {
 		return resp.EmptyInteger, resp.NewDefaultRedisError(fmt.Sprintf("%s expects a string key value", appendCommand))
 	}
-	value := ra.GetItemAtIndex(2).ToString()
-	// Check if there is already a value at key
-	v, ok := gm.Load(key)
-	if ok != true {
-		gm.Store(key, value)
-		return resp.NewInteger(len(value)), resp.EmptyRedisError
-	}
-	gm.Store(key, value+v)
-	return resp.NewInteger(len(value + v)), resp.EmptyRedisError
+	value := getValueBytes(ra.GetItemAtIndex(2))
+	// Update keeps the expiry of key, and concurrent appends all count
+	var length int
+	gm.Update(key, func(v []byte, ok bool) ([]byte, error) {
+		// Build a new slice, the stored one may be shared with readers
+		appended := make([]byte, 0, len(v)+len(value))
+		appended = append(appended, v...)
+		appended = append(appended, value...)
+		length = len(appended)
+		return appended, nil
+	})
+	return resp.NewInteger(int64(length)), resp.EmptyRedisError
 }
 
 // Measure string length of a value if it exists
-func executeStrLenCommand(ra *resp.Array) (resp.Integer, resp.RedisError) {
+func executeStrLenCommand(gm *storage.GenericConcurrentMap, ra *resp.Array) (resp.Integer, resp.RedisError) {
 	numberOfItems := ra.GetNumberOfItems()
 	if numberOfItems == 1 {
 		return resp.EmptyInteger, resp.NewDefaultRedisError("wrong number of arguments for (strlen) command")
@@ -166,10 +194,74 @@ func executeStrLenCommand(ra *resp.Array) (resp.Integer, resp.RedisError) {
 		// If we cannot find it, we return 0
 		return resp.NewInteger(0), resp.EmptyRedisError
 	}
-	return resp.NewInteger(len(value)), resp.EmptyRedisError
+	return resp.NewInteger(int64(len(value))), resp.EmptyRedisError
+}
+
+// Parse a signed 64 bit integer the way Redis does. Only the canonical form is
+// accepted, so no sign for positive numbers, no leading zeros and no spaces
+func parseInteger(b []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != string(b) {
+		return 0, false
+	}
+	return n, true
+}
+
+// Add delta to the integer stored at key, which counts as 0 if the key does
+// not exist, and return the new value
+func executeIncrByCommand(gm *storage.GenericConcurrentMap, key string, delta int64) (resp.Integer, resp.RedisError) {
+	var result int64
+	err := gm.Update(key, func(value []byte, ok bool) ([]byte, error) {
+		var current int64
+		if ok {
+			n, isInteger := parseInteger(value)
+			if !isInteger {
+				return nil, errNotInteger
+			}
+			current = n
+		}
+		if (delta < 0 && current < 0 && delta < math.MinInt64-current) ||
+			(delta > 0 && current > 0 && delta > math.MaxInt64-current) {
+			return nil, errIncrOverflow
+		}
+		result = current + delta
+		return []byte(strconv.FormatInt(result, 10)), nil
+	})
+	if err != nil {
+		return resp.EmptyInteger, err.(resp.RedisError)
+	}
+	return resp.NewInteger(result), resp.EmptyRedisError
+}
+
+// execute INCR, DECR, INCRBY and DECRBY
+func executeIncrDecrCommand(gm *storage.GenericConcurrentMap, ra *resp.Array, command string) (resp.Integer, resp.RedisError) {
+	numberOfItems := ra.GetNumberOfItems()
+	withIncrement := command == incrByCommand || command == decrByCommand
+	if (withIncrement && numberOfItems != 3) || (!withIncrement && numberOfItems != 2) {
+		return resp.EmptyInteger, resp.NewDefaultRedisError(fmt.Sprintf("wrong number of arguments for (%s) command", strings.ToLower(command)))
+	}
+	key, err := getGuardedKey(ra.GetItemAtIndex(1))
+	if err != resp.EmptyRedisError {
+		return resp.EmptyInteger, resp.NewDefaultRedisError(fmt.Sprintf("%s expects a string key value", command))
+	}
+	delta := int64(1)
+	if withIncrement {
+		n, ok := parseInteger(getValueBytes(ra.GetItemAtIndex(2)))
+		if !ok {
+			return resp.EmptyInteger, errNotInteger
+		}
+		delta = n
+	}
+	if command == decrCommand || command == decrByCommand {
+		if delta == math.MinInt64 {
+			return resp.EmptyInteger, errDecrOverflow
+		}
+		delta = -delta
+	}
+	return executeIncrByCommand(gm, key, delta)
 }
 
-func executeSetAndExpiryCommand(ra *resp.Array) (resp.String, resp.RedisError) {
+func executeSetAndExpiryCommand(gm *storage.GenericConcurrentMap, ra *resp.Array) (resp.String, resp.RedisError) {
 	numberOfItems := ra.GetNumberOfItems()
 	if numberOfItems < 4 {
 		return resp.EmptyString, resp.NewDefaultRedisError("wrong number of arguments for (SETEX) command")
@@ -183,7 +275,7 @@ func executeSetAndExpiryCommand(ra *resp.Array) (resp.String, resp.RedisError) {
 	setRa.SetItemAtIndex(0, resp.NewString(setCommand))
 	setRa.SetItemAtIndex(1, ra.GetItemAtIndex(1))
 	setRa.SetItemAtIndex(2, ra.GetItemAtIndex(3))
-	executeSetCommand(setRa, false, false)
+	executeSetCommand(gm, setRa, false, false)
 	ttl, e := strconv.ParseInt(ra.GetItemAtIndex(2).ToString(), 10, 64)
 	if e != nil {
 		return resp.EmptyString, resp.NewDefaultRedisError(fmt.Sprintf("Invalid TTL specified %s", ra.GetItemAtIndex(2).ToString()))
@@ -193,29 +285,34 @@ func executeSetAndExpiryCommand(ra *resp.Array) (resp.String, resp.RedisError) {
 }
 
 // ExecuteStringCommand takes a Array and inspects it to check there is
-// a matching executable command. If no command can be found, it returns error
-func ExecuteStringCommand(ra resp.Array) (resp.IDataType, resp.RedisError) {
+// a matching executable command, which it runs on the keyspace gm. If no
+// command can be found, it returns error
+func ExecuteStringCommand(gm *storage.GenericConcurrentMap, ra resp.Array) (resp.IDataType, resp.RedisError) {
 	if ra.GetNumberOfItems() == 0 {
 		return nil, resp.NewDefaultRedisError("No command found")
 	}
 	first := ra.GetItemAtIndex(0)
-	switch first.ToString() {
+	// Like Redis, command names are case insensitive
+	command := strings.ToUpper(first.ToString())
+	switch command {
 	case getCommand:
-		return executeGetCommand(&ra)
+		return executeGetCommand(gm, &ra)
 	case setCommand:
-		return executeSetCommand(&ra, false, false)
+		return executeSetCommand(gm, &ra, false, false)
 	case getSetCommand:
-		return executeSetCommand(&ra, true, false)
+		return executeSetCommand(gm, &ra, true, false)
 	case deleteCommand:
-		return executeDeleteCommand(&ra)
+		return executeDeleteCommand(gm, &ra)
 	case strLengthCommand:
-		return executeStrLenCommand(&ra)
+		return executeStrLenCommand(gm, &ra)
 	case appendCommand:
-		return executeAppendCommand(&ra)
+		return executeAppendCommand(gm, &ra)
 	case setnxCommand:
-		return executeSetCommand(&ra, false, true)
+		return executeSetCommand(gm, &ra, false, true)
 	case setAndExpireCommand:
-		return executeSetAndExpiryCommand(&ra)
+		return executeSetAndExpiryCommand(gm, &ra)
+	case incrCommand, decrCommand, incrByCommand, decrByCommand:
+		return executeIncrDecrCommand(gm, &ra, command)
 	default:
 		break
 	}
@@ -0,0 +1,84 @@
+package commands_test
+
+import (
+	"golang-redis-mock/client"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandNamesIgnoreCase(t *testing.T) {
+	c := dial(t, startServer(t, nil))
+	_, err := c.Do("set", "foo", "bar")
+	assert.Nil(t, err)
+	value, err := client.String(c.Do("Get", "foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", value)
+	n, err := client.Int(c.Do("incrby", "counter", 2))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	_, err = c.Do("nosuchcommand")
+	assert.Equal(t, "ERR Unknown or disabled command 'nosuchcommand'", err.Error(), "Unknown commands must be reported as sent")
+}
+
+func TestIncrSixtyFourBit(t *testing.T) {
+	c := dial(t, startServer(t, nil))
+	_, err := c.Do("SET", "incr", "9223372036854775806")
+	assert.Nil(t, err)
+	n, err := client.Int64(c.Do("INCR", "incr"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(9223372036854775807), n)
+	_, err = c.Do("INCR", "incr")
+	assert.Equal(t, "ERR increment or decrement would overflow", err.Error())
+	n, err = client.Int64(c.Do("DECRBY", "incr", "9223372036854775807"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+	_, err = c.Do("DECRBY", "incr", "-9223372036854775808")
+	assert.Equal(t, "ERR decrement would overflow", err.Error())
+	n, err = client.Int64(c.Do("INCRBY", "incr", int64(1700000000000)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1700000000000), n, "Epoch milliseconds must not be truncated")
+	_, err = c.Do("INCRBY", "incr", "1.5")
+	assert.Equal(t, "ERR value is not an integer or out of range", err.Error())
+	_, err = c.Do("SET", "incr", "007")
+	assert.Nil(t, err)
+	_, err = c.Do("INCR", "incr")
+	assert.Equal(t, "ERR value is not an integer or out of range", err.Error())
+	n, err = client.Int64(c.Do("DECR", "missing-counter"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), n, "Missing keys count as 0")
+}
+
+func TestAppend(t *testing.T) {
+	s := startServer(t, nil)
+	c := dial(t, s)
+	n, err := client.Int(c.Do("APPEND", "append", "v"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n, "Missing keys count as empty strings")
+	_, err = c.Do("SETEX", "append", 100, "v")
+	assert.Nil(t, err)
+	n, err = client.Int(c.Do("APPEND", "append", "x"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	ttl, ok := s.TTL("append")
+	assert.True(t, ok, "APPEND must keep the expiry")
+	assert.True(t, ttl > 99*time.Second && ttl <= 100*time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		conn := dial(t, s)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				conn.Do("APPEND", "concurrent", "x")
+			}
+		}()
+	}
+	wg.Wait()
+	n, err = client.Int(c.Do("STRLEN", "concurrent"))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, n, "Concurrent appends must not be lost")
+}
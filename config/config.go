@@ -0,0 +1,279 @@
+// Package config holds the settings of the server. They can be given on the
+// command line, in a configuration file in redis.conf syntax, or changed at
+// runtime with CONFIG SET.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang-redis-mock/resp"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Config holds the value of every parameter. Parameter names follow
+// redis.conf, so Port is set with port and ProtoMaxBulkLen with
+// proto-max-bulk-len.
+type Config struct {
+	// File the configuration was loaded from, if any. CONFIG REWRITE writes
+	// the current settings back to it
+	File string
+
+	// Addresses to listen on, none to not listen on TCP at all
+	Bind []string
+	// TCP port to listen on, 0 picks a free port
+	Port int
+	// Path of a unix socket to listen on as well, if any
+	UnixSocket string
+	// Permissions of the unix socket, 0 leaves the default
+	UnixSocketPerm int
+	// TLS port to listen on, with TLSCertFile and TLSKeyFile. 0 turns TLS off
+	TLSPort int
+	// Certificate and private key of the server, in PEM files
+	TLSCertFile string
+	TLSKeyFile  string
+	// Certificate authorities client certificates are verified against
+	TLSCACertFile string
+	// Whether clients must present a certificate: yes, no or optional
+	TLSAuthClients string
+	// TLS versions to accept, like "TLSv1.2 TLSv1.3". Empty accepts TLSv1.2
+	// and later
+	TLSProtocols string
+	// Largest bulk string a client may send, in bytes
+	ProtoMaxBulkLen int64
+	// Close connections idle for this many seconds, 0 never does
+	Timeout int
+	// Seconds to wait for commands in flight when shutting down
+	ShutdownTimeout int
+	// Password clients must AUTH with, none if empty
+	RequirePass string
+	// File the ACL users are loaded from at startup, none if empty
+	ACLFile string
+	// Number of denials ACL LOG keeps
+	ACLLogMaxLen int
+	// Number of clients that may be connected at the same time
+	MaxClients int
+	// Seconds between TCP keepalive probes, 0 to turn them off
+	TCPKeepAlive int
+	// Limits of the replies waiting for clients, by client class
+	ClientOutputBufferLimits map[string]OutputBufferLimit
+}
+
+// Client classes with their own output buffer limits
+const (
+	ClientNormal  = "normal"
+	ClientReplica = "replica"
+	ClientPubSub  = "pubsub"
+)
+
+// OutputBufferLimit limits the bytes of replies waiting to be sent to a
+// client. Clients are disconnected as soon as they reach the hard limit, or
+// once they stayed at the soft limit for SoftSeconds. 0 means no limit.
+type OutputBufferLimit struct {
+	Hard        int64
+	Soft        int64
+	SoftSeconds int
+}
+
+// Default returns the configuration used when nothing else is given
+func Default() *Config {
+	return &Config{
+		Bind:            []string{"localhost"},
+		Port:            6382,
+		TLSAuthClients:  "yes",
+		ProtoMaxBulkLen: resp.MaxBulkSizeLength,
+		Timeout:         0,
+		ShutdownTimeout: 10,
+		ACLLogMaxLen:    128,
+		MaxClients:      10000,
+		TCPKeepAlive:    300,
+		ClientOutputBufferLimits: map[string]OutputBufferLimit{
+			ClientNormal:  {},
+			ClientReplica: {Hard: 256 * 1024 * 1024, Soft: 64 * 1024 * 1024, SoftSeconds: 60},
+			ClientPubSub:  {Hard: 32 * 1024 * 1024, Soft: 8 * 1024 * 1024, SoftSeconds: 60},
+		},
+	}
+}
+
+// Clone returns a copy of c that can be changed without affecting c
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Bind = append([]string{}, c.Bind...)
+	clone.ClientOutputBufferLimits = make(map[string]OutputBufferLimit, len(c.ClientOutputBufferLimits))
+	for class, limit := range c.ClientOutputBufferLimits {
+		clone.ClientOutputBufferLimits[class] = limit
+	}
+	return &clone
+}
+
+// Parameters returns the names of all parameters
+func Parameters() []string {
+	names := make([]string, len(parameters))
+	for i, p := range parameters {
+		names[i] = p.name
+	}
+	return names
+}
+
+// Get returns the value of a parameter the way CONFIG GET shows it
+func (c *Config) Get(name string) (string, bool) {
+	p, ok := lookupParameter(name)
+	if !ok {
+		return "", false
+	}
+	return p.get(c), true
+}
+
+// Set changes a parameter, as if args followed its name in the configuration
+// file
+func (c *Config) Set(name string, args ...string) error {
+	p, ok := lookupParameter(name)
+	if !ok {
+		return errBadDirective
+	}
+	if len(args) == 0 || (!p.multiArg && len(args) != 1) {
+		return errBadDirective
+	}
+	return p.set(c, args)
+}
+
+// IsMutable reports whether a parameter may be changed at runtime
+func IsMutable(name string) bool {
+	p, ok := lookupParameter(name)
+	return ok && p.mutable
+}
+
+// IsMultiArg reports whether a parameter takes more than one argument, which
+// CONFIG SET splits out of its value
+func IsMultiArg(name string) bool {
+	p, ok := lookupParameter(name)
+	return ok && p.multiArg
+}
+
+// errBadDirective is returned for unknown parameters, or a wrong number of
+// arguments. The wording follows Redis
+var errBadDirective = errors.New("Bad directive or wrong number of arguments")
+
+// LoadFile reads a configuration file in redis.conf syntax and applies it
+// on top of c. Each line holds a parameter name followed by its arguments,
+// blank lines and lines starting with # are ignored.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.load(f, path); err != nil {
+		return err
+	}
+	c.File = path
+	return nil
+}
+
+// Apply the configuration lines read from r. source names r in errors
+func (c *Config) load(r io.Reader, source string) error {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		args, ok := resp.SplitArgs(line)
+		if !ok {
+			return lineError(source, lineNumber, line, errors.New("Unbalanced quotes in configuration line"))
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if err := c.Set(args[0], args[1:]...); err != nil {
+			return lineError(source, lineNumber, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Describe an error in a configuration line the way Redis does
+func lineError(source string, lineNumber int, line string, err error) error {
+	return fmt.Errorf("Reading the configuration file %s, at line %d\n>>> '%s'\n%s", source, lineNumber, line, err.Error())
+}
+
+// Parse reads the configuration from command line arguments, the way
+// redis-server does: an optional path to a configuration file, followed by
+// parameters as --name value. Parameters given on the command line override
+// the ones in the file.
+//
+//	redis-mock-server /etc/redis.conf --port 0 --timeout 30
+func Parse(args []string) (*Config, error) {
+	c := Default()
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		if err := c.LoadFile(args[0]); err != nil {
+			return nil, err
+		}
+		args = args[1:]
+	}
+	// Turn the options into configuration lines, like Redis does
+	var lines []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--") {
+			lines = append(lines, arg[2:])
+			continue
+		}
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("Invalid argument '%s', parameters must start with --", arg)
+		}
+		lines[len(lines)-1] += " " + quote(arg)
+	}
+	if err := c.load(strings.NewReader(strings.Join(lines, "\n")), "(command line)"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Quote an argument if it has to be, so that it reads back unchanged
+func quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\r\n\"'\\") {
+		return arg
+	}
+	return fmt.Sprintf("%q", arg)
+}
+
+// Store holds the configuration of a running server, which may be read from
+// many connections while one of them changes it with CONFIG SET. Changes are
+// made on a copy, which replaces the current configuration as a whole, so
+// readers always see a consistent configuration.
+type Store struct {
+	// Serializes changes
+	mu      sync.Mutex
+	current atomic.Value
+}
+
+// NewStore creates a Store holding c
+func NewStore(c *Config) *Store {
+	s := &Store{}
+	s.current.Store(c)
+	return s
+}
+
+// Load returns the current configuration, which must not be modified
+func (s *Store) Load() *Config {
+	return s.current.Load().(*Config)
+}
+
+// Update applies update to a copy of the current configuration, which
+// replaces the current one unless update fails
+func (s *Store) Update(update func(c *Config) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.Load().Clone()
+	if err := update(c); err != nil {
+		return err
+	}
+	s.current.Store(c)
+	return nil
+}
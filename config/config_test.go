@@ -0,0 +1,215 @@
+package config
+
+import (
+	"golang-redis-mock/resp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Write content to a configuration file in a temporary directory
+func writeConfigFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "redis-mock-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "redis.conf")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseDefaults(t *testing.T) {
+	c, err := Parse(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, Default(), c)
+}
+
+func TestParseFlags(t *testing.T) {
+	c, err := Parse([]string{"--port", "0", "--bind", "127.0.0.1", "::1", "--timeout", "30", "--proto-max-bulk-len", "2mb"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, c.Port, "Port 0 picks a free port")
+	assert.Equal(t, []string{"127.0.0.1", "::1"}, c.Bind)
+	assert.Equal(t, 30, c.Timeout)
+	assert.Equal(t, int64(2*1024*1024), c.ProtoMaxBulkLen)
+	assert.Equal(t, "", c.File)
+
+	_, err = Parse([]string{"--port", "65536"})
+	assert.Contains(t, err.Error(), "argument must be between 0 and 65535 inclusive")
+	_, err = Parse([]string{"--nosuchparameter", "1"})
+	assert.Contains(t, err.Error(), "Bad directive or wrong number of arguments")
+	_, err = Parse([]string{"--timeout", "1", "2"})
+	assert.Contains(t, err.Error(), "Bad directive or wrong number of arguments")
+}
+
+func TestParseFile(t *testing.T) {
+	path := writeConfigFile(t, "# Comment\n\nport 7000\nTIMEOUT 10\nproto-max-bulk-len \"4mb\"\n")
+	defer os.RemoveAll(filepath.Dir(path))
+	c, err := Parse([]string{path, "--timeout", "20"})
+	assert.Nil(t, err)
+	assert.Equal(t, path, c.File)
+	assert.Equal(t, 7000, c.Port)
+	assert.Equal(t, 20, c.Timeout, "Flags must override the file")
+	assert.Equal(t, int64(4*1024*1024), c.ProtoMaxBulkLen)
+
+	bad := writeConfigFile(t, "port 7000\nport abc\n")
+	defer os.RemoveAll(filepath.Dir(bad))
+	_, err = Parse([]string{bad})
+	assert.Contains(t, err.Error(), "at line 2")
+	assert.Contains(t, err.Error(), "argument couldn't be parsed into an integer")
+
+	_, err = Parse([]string{filepath.Join(filepath.Dir(bad), "missing.conf")})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGetSet(t *testing.T) {
+	c := Default()
+	assert.Nil(t, c.Set("Timeout", "5"))
+	value, ok := c.Get("timeout")
+	assert.True(t, ok)
+	assert.Equal(t, "5", value)
+	assert.NotNil(t, c.Set("timeout", "-1"))
+	assert.NotNil(t, c.Set("proto-max-bulk-len", "1k"), "proto-max-bulk-len must be at least 1mb")
+	assert.NotNil(t, c.Set("proto-max-bulk-len", "9223372036854775807"), "proto-max-bulk-len must be at most 512mb")
+	_, ok = c.Get("nosuchparameter")
+	assert.False(t, ok)
+	assert.True(t, IsMutable("timeout"))
+	assert.False(t, IsMutable("port"))
+	assert.False(t, IsMutable("nosuchparameter"))
+	assert.True(t, IsMultiArg("bind"))
+	assert.False(t, IsMultiArg("requirepass"))
+}
+
+func TestParseMemory(t *testing.T) {
+	sizes := map[string]int64{
+		"100":  100,
+		"1k":   1000,
+		"1kb":  1024,
+		"2MB":  2 * 1024 * 1024,
+		"1g":   1000 * 1000 * 1000,
+		"1Gb":  1024 * 1024 * 1024,
+		"512b": 512,
+	}
+	for s, n := range sizes {
+		parsed, err := parseMemory(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, n, parsed, s)
+	}
+	for _, s := range []string{"", "mb", "-1", "1tb", "1.5mb", "99999999999gb"} {
+		_, err := parseMemory(s)
+		assert.NotNil(t, err, s)
+	}
+	assert.Equal(t, "512mb", formatMemory(512*1024*1024))
+	assert.Equal(t, "1000", formatMemory(1000))
+	assert.Equal(t, "0", formatMemory(0))
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(Default())
+	before := s.Load()
+	err := s.Update(func(c *Config) error {
+		return c.Set("proto-max-bulk-len", "2mb")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(resp.MaxBulkSizeLength), before.ProtoMaxBulkLen, "Updates must not change loaded configurations")
+	assert.Equal(t, int64(2*1024*1024), s.Load().ProtoMaxBulkLen)
+
+	err = s.Update(func(c *Config) error {
+		c.Set("timeout", "10")
+		return c.Set("timeout", "x")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, s.Load().Timeout, "Failed updates must not change anything")
+}
+
+func TestRewrite(t *testing.T) {
+	path := writeConfigFile(t, "# Server settings\nport 7000\n\ntimeout 10\n# again\ntimeout 20\n")
+	defer os.RemoveAll(filepath.Dir(path))
+	c, err := Parse([]string{path})
+	assert.Nil(t, err)
+	c.Set("timeout", "30")
+	c.Set("proto-max-bulk-len", "8mb")
+	assert.Nil(t, c.Rewrite())
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "# Server settings\nport 7000\n\ntimeout 30\n# again\nproto-max-bulk-len 8mb\n", string(content))
+
+	reloaded, err := Parse([]string{path})
+	assert.Nil(t, err)
+	assert.Equal(t, c, reloaded)
+
+	assert.Equal(t, ErrNoConfigFile, Default().Rewrite())
+}
+
+func TestRewriteQuotes(t *testing.T) {
+	path := writeConfigFile(t, "")
+	defer os.RemoveAll(filepath.Dir(path))
+	c, _ := Parse([]string{path})
+	c.Set("bind", "127.0.0.1", "::1")
+	assert.Nil(t, c.Rewrite())
+	content, _ := ioutil.ReadFile(path)
+	assert.True(t, strings.HasPrefix(string(content), "bind 127.0.0.1 ::1\n"))
+	reloaded, err := Parse([]string{path})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1", "::1"}, reloaded.Bind)
+
+	c.Set("bind", "")
+	assert.Nil(t, c.Rewrite())
+	reloaded, err = Parse([]string{path})
+	assert.Nil(t, err)
+	assert.Empty(t, reloaded.Bind, "bind \"\" must turn TCP off")
+}
+
+func TestUnixSocketParameters(t *testing.T) {
+	c, err := Parse([]string{"--unixsocket", "/tmp/redis.sock", "--unixsocketperm", "770"})
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/redis.sock", c.UnixSocket)
+	assert.Equal(t, 0770, c.UnixSocketPerm)
+	value, _ := c.Get("unixsocketperm")
+	assert.Equal(t, "770", value)
+	assert.NotNil(t, c.Set("unixsocketperm", "800"))
+	assert.NotNil(t, c.Set("unixsocketperm", "1000"))
+	assert.False(t, IsMutable("unixsocket"))
+}
+
+func TestTLSParameters(t *testing.T) {
+	c, err := Parse([]string{"--tls-port", "6390", "--tls-auth-clients", "OPTIONAL", "--tls-protocols", "TLSv1.2 TLSv1.3"})
+	assert.Nil(t, err)
+	assert.Equal(t, 6390, c.TLSPort)
+	assert.Equal(t, "optional", c.TLSAuthClients)
+	assert.Equal(t, "TLSv1.2 TLSv1.3", c.TLSProtocols)
+	assert.Nil(t, c.Set("tls-protocols", "TLSv1.3"))
+	assert.NotNil(t, c.Set("tls-protocols", "SSLv3"))
+	assert.NotNil(t, c.Set("tls-auth-clients", "maybe"))
+	assert.Equal(t, "yes", Default().TLSAuthClients, "Client certificates are required by default, like in Redis")
+}
+
+func TestClientOutputBufferLimitParameter(t *testing.T) {
+	c := Default()
+	value, _ := c.Get("client-output-buffer-limit")
+	assert.Equal(t, "normal 0 0 0 slave 268435456 67108864 60 pubsub 33554432 8388608 60", value)
+	assert.Nil(t, c.Set("client-output-buffer-limit", "normal", "1mb", "512kb", "10", "replica", "1", "1", "1"))
+	assert.Equal(t, OutputBufferLimit{Hard: 1024 * 1024, Soft: 512 * 1024, SoftSeconds: 10}, c.ClientOutputBufferLimits[ClientNormal])
+	assert.Equal(t, OutputBufferLimit{Hard: 1, Soft: 1, SoftSeconds: 1}, c.ClientOutputBufferLimits[ClientReplica])
+	assert.Equal(t, int64(32*1024*1024), c.ClientOutputBufferLimits[ClientPubSub].Hard, "Classes not given must keep their limits")
+	assert.Equal(t, OutputBufferLimit{}, Default().ClientOutputBufferLimits[ClientNormal], "Changes must not leak into other configurations")
+
+	assert.NotNil(t, c.Set("client-output-buffer-limit", "normal", "1mb", "0"))
+	assert.NotNil(t, c.Set("client-output-buffer-limit", "master", "0", "0", "0"))
+	assert.NotNil(t, c.Set("client-output-buffer-limit", "normal", "x", "0", "0"))
+
+	path := writeConfigFile(t, "client-output-buffer-limit pubsub 64mb 16mb 30\n")
+	defer os.RemoveAll(filepath.Dir(path))
+	c, err := Parse([]string{path})
+	assert.Nil(t, err)
+	assert.Nil(t, c.Rewrite())
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "client-output-buffer-limit normal 0 0 0 slave 256mb 64mb 60 pubsub 64mb 16mb 30\n", string(content))
+	reloaded, err := Parse([]string{path})
+	assert.Nil(t, err)
+	assert.Equal(t, c, reloaded)
+}
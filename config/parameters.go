@@ -0,0 +1,298 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"golang-redis-mock/resp"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A parameter that can be set in the configuration file and with CONFIG SET
+type parameter struct {
+	name string
+	// Whether CONFIG SET may change it
+	mutable bool
+	// Whether it takes more than one argument
+	multiArg bool
+	// Formats the value for CONFIG GET
+	get func(c *Config) string
+	// Formats the value for CONFIG REWRITE, defaults to get
+	rewrite func(c *Config) string
+	set     func(c *Config, args []string) error
+}
+
+var parameters = []parameter{
+	{
+		name:     "bind",
+		multiArg: true,
+		get:      func(c *Config) string { return strings.Join(c.Bind, " ") },
+		set: func(c *Config, args []string) error {
+			// bind "" turns TCP off
+			c.Bind = []string{}
+			for _, arg := range args {
+				if arg != "" {
+					c.Bind = append(c.Bind, arg)
+				}
+			}
+			return nil
+		},
+	},
+	intParameter("port", false, 0, 65535, func(c *Config) *int { return &c.Port }),
+	stringParameter("unixsocket", false, func(c *Config) *string { return &c.UnixSocket }),
+	octalParameter("unixsocketperm", false, 0, 0777, func(c *Config) *int { return &c.UnixSocketPerm }),
+	intParameter("tls-port", false, 0, 65535, func(c *Config) *int { return &c.TLSPort }),
+	stringParameter("tls-cert-file", false, func(c *Config) *string { return &c.TLSCertFile }),
+	stringParameter("tls-key-file", false, func(c *Config) *string { return &c.TLSKeyFile }),
+	stringParameter("tls-ca-cert-file", false, func(c *Config) *string { return &c.TLSCACertFile }),
+	enumParameter("tls-auth-clients", false, []string{"yes", "no", "optional"}, func(c *Config) *string { return &c.TLSAuthClients }),
+	{
+		name:     "tls-protocols",
+		multiArg: true,
+		get:      func(c *Config) string { return c.TLSProtocols },
+		set: func(c *Config, args []string) error {
+			// The versions may be given as one quoted argument or several
+			protocols := strings.Fields(strings.Join(args, " "))
+			for _, protocol := range protocols {
+				if _, ok := TLSVersions[protocol]; !ok {
+					return fmt.Errorf("unknown TLS protocol '%s'", protocol)
+				}
+			}
+			c.TLSProtocols = strings.Join(protocols, " ")
+			return nil
+		},
+	},
+	memoryParameter("proto-max-bulk-len", true, 1024*1024, resp.MaxStringLength, func(c *Config) *int64 { return &c.ProtoMaxBulkLen }),
+	intParameter("timeout", true, 0, math.MaxInt32, func(c *Config) *int { return &c.Timeout }),
+	intParameter("maxclients", true, 1, math.MaxInt32, func(c *Config) *int { return &c.MaxClients }),
+	intParameter("tcp-keepalive", true, 0, math.MaxInt32, func(c *Config) *int { return &c.TCPKeepAlive }),
+	{
+		name:     "client-output-buffer-limit",
+		mutable:  true,
+		multiArg: true,
+		get: func(c *Config) string {
+			return formatOutputBufferLimits(c, func(n int64) string { return strconv.FormatInt(n, 10) })
+		},
+		rewrite: func(c *Config) string { return formatOutputBufferLimits(c, formatMemory) },
+		set:     setOutputBufferLimits,
+	},
+	stringParameter("requirepass", true, func(c *Config) *string { return &c.RequirePass }),
+	stringParameter("aclfile", false, func(c *Config) *string { return &c.ACLFile }),
+	intParameter("acllog-max-len", true, 0, math.MaxInt32, func(c *Config) *int { return &c.ACLLogMaxLen }),
+	intParameter("shutdown-timeout", true, 0, math.MaxInt32, func(c *Config) *int { return &c.ShutdownTimeout }),
+}
+
+// Classes of client-output-buffer-limit in the order Redis lists them, with
+// the names it uses for them
+var outputBufferClasses = []struct {
+	class string
+	name  string
+}{
+	{ClientNormal, "normal"},
+	{ClientReplica, "slave"},
+	{ClientPubSub, "pubsub"},
+}
+
+// Format the output buffer limits as "<class> <hard> <soft> <soft seconds>"
+// for every class, with sizes formatted by format
+func formatOutputBufferLimits(c *Config, format func(n int64) string) string {
+	var parts []string
+	for _, class := range outputBufferClasses {
+		limit := c.ClientOutputBufferLimits[class.class]
+		parts = append(parts, class.name, format(limit.Hard), format(limit.Soft), strconv.Itoa(limit.SoftSeconds))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set the output buffer limits of the classes in args, given as groups of
+// "<class> <hard> <soft> <soft seconds>". Other classes keep their limits
+func setOutputBufferLimits(c *Config, args []string) error {
+	// The limits may be given as one quoted argument or several
+	args = strings.Fields(strings.Join(args, " "))
+	if len(args) == 0 || len(args)%4 != 0 {
+		return errors.New("Wrong number of arguments in buffer limit configuration.")
+	}
+	limits := map[string]OutputBufferLimit{}
+	for i := 0; i < len(args); i += 4 {
+		class := strings.ToLower(args[i])
+		switch class {
+		case "normal", "pubsub":
+		case "slave", "replica":
+			class = ClientReplica
+		default:
+			return errors.New("Invalid client class specified in buffer limit configuration.")
+		}
+		hard, hardErr := parseMemory(args[i+1])
+		soft, softErr := parseMemory(args[i+2])
+		seconds, secondsErr := strconv.Atoi(args[i+3])
+		if hardErr != nil || softErr != nil || secondsErr != nil || seconds < 0 {
+			return errors.New("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+		}
+		limits[class] = OutputBufferLimit{Hard: hard, Soft: soft, SoftSeconds: seconds}
+	}
+	for class, limit := range limits {
+		c.ClientOutputBufferLimits[class] = limit
+	}
+	return nil
+}
+
+// Find a parameter by name, ignoring case
+func lookupParameter(name string) (*parameter, bool) {
+	name = strings.ToLower(name)
+	for i := range parameters {
+		if parameters[i].name == name {
+			return &parameters[i], true
+		}
+	}
+	return nil, false
+}
+
+// A parameter holding an integer between min and max
+func intParameter(name string, mutable bool, min int, max int, field func(c *Config) *int) parameter {
+	return parameter{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, args []string) error {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an integer")
+			}
+			if n < min || n > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+// A parameter holding any string
+func stringParameter(name string, mutable bool, field func(c *Config) *string) parameter {
+	return parameter{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return *field(c) },
+		set: func(c *Config, args []string) error {
+			*field(c) = args[0]
+			return nil
+		},
+	}
+}
+
+// A parameter holding one of values, ignoring case
+func enumParameter(name string, mutable bool, values []string, field func(c *Config) *string) parameter {
+	return parameter{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return *field(c) },
+		set: func(c *Config, args []string) error {
+			for _, value := range values {
+				if strings.EqualFold(args[0], value) {
+					*field(c) = value
+					return nil
+				}
+			}
+			return fmt.Errorf("argument must be one of the following: %s", strings.Join(values, ", "))
+		},
+	}
+}
+
+// A parameter holding an octal number between min and max, like file
+// permissions
+func octalParameter(name string, mutable bool, min int, max int, field func(c *Config) *int) parameter {
+	return parameter{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return strconv.FormatInt(int64(*field(c)), 8) },
+		set: func(c *Config, args []string) error {
+			n, err := strconv.ParseInt(args[0], 8, 64)
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an octal number")
+			}
+			if n < int64(min) || n > int64(max) {
+				return fmt.Errorf("argument must be between %o and %o inclusive", min, max)
+			}
+			*field(c) = int(n)
+			return nil
+		},
+	}
+}
+
+// A parameter holding a number of bytes between min and max, which may be
+// given with a unit like 512mb
+func memoryParameter(name string, mutable bool, min int64, max int64, field func(c *Config) *int64) parameter {
+	return parameter{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return strconv.FormatInt(*field(c), 10) },
+		rewrite: func(c *Config) string { return formatMemory(*field(c)) },
+		set: func(c *Config, args []string) error {
+			n, err := parseMemory(args[0])
+			if err != nil {
+				return err
+			}
+			if n < min || n > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+// Units of memory sizes, from largest to smallest. k, m and g are powers of
+// 1000, kb, mb and gb powers of 1024, like in redis.conf
+var memoryUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"gb", 1024 * 1024 * 1024},
+	{"mb", 1024 * 1024},
+	{"kb", 1024},
+	{"g", 1000 * 1000 * 1000},
+	{"m", 1000 * 1000},
+	{"k", 1000},
+	{"b", 1},
+}
+
+// Parse a memory size like 1024, 1k or 512MB into bytes
+func parseMemory(s string) (int64, error) {
+	lower := strings.ToLower(s)
+	multiplier := int64(1)
+	for _, unit := range memoryUnits {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower = lower[:len(lower)-len(unit.suffix)]
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("argument must be a memory value")
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, errors.New("argument must be a memory value")
+	}
+	return n * multiplier, nil
+}
+
+// Format a memory size with the largest binary unit that divides it
+func formatMemory(n int64) string {
+	for _, unit := range memoryUnits[:3] {
+		if n != 0 && n%unit.multiplier == 0 {
+			return strconv.FormatInt(n/unit.multiplier, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// TLSVersions maps the names tls-protocols accepts to crypto/tls versions
+var TLSVersions = map[string]uint16{
+	"TLSv1":   tls.VersionTLS10,
+	"TLSv1.1": tls.VersionTLS11,
+	"TLSv1.2": tls.VersionTLS12,
+	"TLSv1.3": tls.VersionTLS13,
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"errors"
+	"golang-redis-mock/resp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoConfigFile is returned by Rewrite when the configuration was not
+// loaded from a file
+var ErrNoConfigFile = errors.New("The server is running without a config file")
+
+// Rewrite writes the configuration back to the file it was loaded from, the
+// way CONFIG REWRITE does. Comments, blank lines and the order of the file
+// are kept, lines setting a parameter are rewritten with its current value,
+// and parameters that differ from their default but are not in the file yet
+// are appended. The file is replaced atomically.
+func (c *Config) Rewrite() error {
+	if c.File == "" {
+		return ErrNoConfigFile
+	}
+	content, err := ioutil.ReadFile(c.File)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	}
+	lines = c.rewriteLines(lines)
+
+	f, err := ioutil.TempFile(filepath.Dir(c.File), ".redis-mock-rewrite-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(c.File); err == nil {
+		os.Chmod(f.Name(), info.Mode())
+	}
+	return os.Rename(f.Name(), c.File)
+}
+
+// Rewrite the lines of a configuration file with the current settings
+func (c *Config) rewriteLines(lines []string) []string {
+	defaults := Default()
+	rewritten := map[string]bool{}
+	var result []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			result = append(result, line)
+			continue
+		}
+		args, ok := resp.SplitArgs(trimmed)
+		if !ok || len(args) == 0 {
+			result = append(result, line)
+			continue
+		}
+		p, ok := lookupParameter(args[0])
+		if !ok {
+			result = append(result, line)
+			continue
+		}
+		// A parameter set more than once is only kept where it first appeared
+		if !rewritten[p.name] {
+			result = append(result, p.line(c))
+			rewritten[p.name] = true
+		}
+	}
+	for i := range parameters {
+		p := &parameters[i]
+		if rewritten[p.name] || p.line(c) == p.line(defaults) {
+			continue
+		}
+		result = append(result, p.line(c))
+	}
+	return result
+}
+
+// Format the configuration line setting p to its value in c
+func (p *parameter) line(c *Config) string {
+	var value string
+	if p.rewrite != nil {
+		value = p.rewrite(c)
+	} else {
+		value = p.get(c)
+	}
+	if !p.multiArg {
+		return p.name + " " + quote(value)
+	}
+	// Quote each argument of multi argument parameters on its own
+	args := strings.Fields(value)
+	if len(args) == 0 {
+		return p.name + " " + quote("")
+	}
+	for i, arg := range args {
+		args[i] = quote(arg)
+	}
+	return p.name + " " + strings.Join(args, " ")
+}
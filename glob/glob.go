@@ -0,0 +1,130 @@
+// Package glob matches strings against the glob-style patterns Redis uses for
+// KEYS, CONFIG GET and ACL rules
+package glob
+
+// Patterns understand the following:
+//
+//	*      any sequence of characters, including none
+//	?      any single character
+//	[abc]  any of the characters in brackets, [^abc] any other character
+//	[a-z]  any character in the range
+//	\x     the character x, even if it is special
+//
+// Unlike path.Match, * and ? match slashes too, and malformed patterns do
+// not fail, they just match less.
+
+// Match reports whether s matches pattern
+func Match(pattern string, s string) bool {
+	return match(pattern, s, false)
+}
+
+// MatchNoCase reports whether s matches pattern, ignoring ASCII case
+func MatchNoCase(pattern string, s string) bool {
+	return match(pattern, s, true)
+}
+
+func match(pattern string, s string, nocase bool) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// Collapse consecutive stars
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if match(pattern[1:], s[i:], nocase) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			matched, rest := matchClass(pattern[1:], s[0], nocase)
+			if !matched {
+				return false
+			}
+			pattern = rest
+			s = s[1:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || !equal(pattern[0], s[0], nocase) {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// Match c against the character class at the start of pattern, just past the
+// opening bracket. Returns whether it matched and the pattern after the class
+func matchClass(pattern string, c byte, nocase bool) (bool, string) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			if equal(pattern[1], c, nocase) {
+				matched = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			if nocase {
+				lc := lower(c)
+				if lower(start) <= lc && lc <= lower(end) {
+					matched = true
+				}
+			} else if start <= c && c <= end {
+				matched = true
+			}
+			pattern = pattern[3:]
+		default:
+			if equal(pattern[0], c, nocase) {
+				matched = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		// Skip the closing bracket
+		pattern = pattern[1:]
+	}
+	return matched != negate, pattern
+}
+
+// Compare two characters, ignoring ASCII case if nocase is set
+func equal(a byte, b byte, nocase bool) bool {
+	if nocase {
+		return lower(a) == lower(b)
+	}
+	return a == b
+}
+
+// Lower case an ASCII letter
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,47 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	matches := map[string][]string{
+		"*":           {"", "foo", "a/b"},
+		"h?llo":       {"hello", "hallo"},
+		"h*llo":       {"hllo", "heeeello"},
+		"h[ae]llo":    {"hello", "hallo"},
+		"h[^e]llo":    {"hallo", "hbllo"},
+		"h[a-b]llo":   {"hallo", "hbllo"},
+		"user:*:name": {"user:1:name", "user::name"},
+		"a\\*b":       {"a*b"},
+		"[\\]]":       {"]"},
+		"**x":         {"x", "abx"},
+	}
+	for pattern, strs := range matches {
+		for _, s := range strs {
+			assert.True(t, Match(pattern, s), "%q must match %q", pattern, s)
+		}
+	}
+	mismatches := map[string][]string{
+		"h?llo":     {"hllo", "heello"},
+		"h[ae]llo":  {"hillo"},
+		"h[^e]llo":  {"hello"},
+		"h[a-b]llo": {"hcllo"},
+		"a\\*b":     {"axb"},
+		"foo":       {"FOO", "foox", "fo"},
+		"[abc":      {"d"},
+	}
+	for pattern, strs := range mismatches {
+		for _, s := range strs {
+			assert.False(t, Match(pattern, s), "%q must not match %q", pattern, s)
+		}
+	}
+}
+
+func TestMatchNoCase(t *testing.T) {
+	assert.True(t, MatchNoCase("MAX*", "maxclients"))
+	assert.True(t, MatchNoCase("[A-C]x", "bX"))
+	assert.False(t, Match("MAX*", "maxclients"))
+}
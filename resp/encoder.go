@@ -0,0 +1,131 @@
+package resp
+
+import "io"
+
+// Protocol versions a connection can speak. Connections start with RESP2
+// and may switch with the HELLO command
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// Encoder writes RESP encoded values to an underlying writer. Servers use it
+// to send replies to clients, so that nothing but valid RESP reaches the wire.
+type Encoder struct {
+	w        io.Writer
+	protocol int
+}
+
+// NewEncoder creates a new Encoder that writes to w using RESP2
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, protocol: RESP2}
+}
+
+// SetProtocol sets the protocol version used for subsequent values
+func (e *Encoder) SetProtocol(protocol int) {
+	e.protocol = protocol
+}
+
+// Encode writes the RESP representation of dt. A nil value is written as
+// a null bulk string, which is how Redis replies with (nil). Values are
+// converted to the protocol version of the encoder first, so commands can
+// reply with RESP3 types regardless of the protocol of the connection.
+func (e *Encoder) Encode(dt IDataType) error {
+	if dt == nil {
+		dt = EmptyBulkString
+	}
+	_, err := e.w.Write(ConvertToProtocol(dt, e.protocol).Encode())
+	return err
+}
+
+// ConvertToProtocol returns the closest equivalent of dt in the given protocol
+// version. For RESP2, maps are flattened into arrays of alternating keys and
+// values, sets and pushes become arrays, doubles and big numbers become bulk
+// strings and booleans become integers. For RESP3, null bulk strings and null
+// arrays become the RESP3 null. Aggregates are converted recursively.
+func ConvertToProtocol(dt IDataType, protocol int) IDataType {
+	switch v := dt.(type) {
+	case *Array:
+		return ConvertToProtocol(*v, protocol)
+	case *Map:
+		return ConvertToProtocol(*v, protocol)
+	case *Set:
+		return ConvertToProtocol(*v, protocol)
+	case *Push:
+		return ConvertToProtocol(*v, protocol)
+	case BulkString:
+		if v.IsNull() && protocol == RESP3 {
+			return EmptyNull
+		}
+	case Array:
+		if v.IsNull() {
+			if protocol == RESP3 {
+				return EmptyNull
+			}
+			return v
+		}
+		return Array{items: convertItems(v.items, protocol)}
+	case Map:
+		keys := convertItems(v.keys, protocol)
+		values := convertItems(v.values, protocol)
+		if protocol == RESP3 {
+			return Map{keys: keys, values: values}
+		}
+		items := make([]IDataType, 0, 2*len(keys))
+		for i := range keys {
+			items = append(items, keys[i], values[i])
+		}
+		return Array{items: items}
+	case Set:
+		if protocol == RESP3 {
+			return Set{items: convertItems(v.items, protocol)}
+		}
+		return Array{items: convertItems(v.items, protocol)}
+	case Push:
+		if protocol == RESP3 {
+			return Push{items: convertItems(v.items, protocol)}
+		}
+		return Array{items: convertItems(v.items, protocol)}
+	case Attribute:
+		value := v.value
+		if value == nil {
+			value = EmptyBulkString
+		}
+		if protocol == RESP3 {
+			return Attribute{attributes: v.attributes, value: ConvertToProtocol(value, protocol)}
+		}
+		// RESP2 has no way of sending attributes, only the reply is kept
+		return ConvertToProtocol(value, protocol)
+	}
+	if protocol == RESP3 {
+		return dt
+	}
+	switch v := dt.(type) {
+	case Double:
+		return BulkString{value: []byte(v.ToString())}
+	case BigNumber:
+		return BulkString{value: []byte(v.ToString())}
+	case VerbatimString:
+		return BulkString{value: v.value}
+	case Boolean:
+		if v.value {
+			return NewInteger(1)
+		}
+		return NewInteger(0)
+	case Null:
+		return EmptyBulkString
+	}
+	return dt
+}
+
+// Convert each item of an aggregate, unset items as null
+func convertItems(items []IDataType, protocol int) []IDataType {
+	converted := make([]IDataType, len(items))
+	for i, item := range items {
+		if item == nil {
+			item = EmptyBulkString
+		}
+		converted[i] = ConvertToProtocol(item, protocol)
+	}
+	return converted
+}
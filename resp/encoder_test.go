@@ -0,0 +1,51 @@
+package resp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	assert.Nil(t, e.Encode(NewString("OK")))
+	assert.Nil(t, e.Encode(nil), "Encoding nil must not fail")
+	assert.Nil(t, e.Encode(NewInteger(7)))
+	assert.Equal(t, "+OK\r\n$-1\r\n:7\r\n", buf.String(), "Encoder must write values back to back, nil as null bulk string")
+}
+
+func TestEncoderProtocol(t *testing.T) {
+	m := NewMap()
+	m.Add(NewString("score"), NewDouble(1.5))
+	m.Add(NewString("found"), NewBoolean(true))
+	m.Add(NewString("missing"), NewNull())
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	assert.Nil(t, e.Encode(m))
+	assert.Equal(t, "*6\r\n+score\r\n$3\r\n1.5\r\n+found\r\n:1\r\n+missing\r\n$-1\r\n", buf.String(), "RESP2 must flatten maps and downgrade RESP3 scalars")
+
+	buf.Reset()
+	e.SetProtocol(RESP3)
+	assert.Nil(t, e.Encode(m))
+	assert.Equal(t, "%3\r\n+score\r\n,1.5\r\n+found\r\n#t\r\n+missing\r\n_\r\n", buf.String(), "RESP3 must encode maps natively")
+
+	buf.Reset()
+	assert.Nil(t, e.Encode(EmptyBulkString))
+	assert.Nil(t, e.Encode(*NewNullArray()))
+	assert.Equal(t, "_\r\n_\r\n", buf.String(), "RESP3 must use the null type for null bulk strings and arrays")
+}
+
+func TestConvertToProtocol(t *testing.T) {
+	st := NewSet()
+	st.Add(NewBoolean(false))
+	assert.Equal(t, "*1\r\n:0\r\n", string(ConvertToProtocol(st, RESP2).Encode()), "Sets must become arrays in RESP2")
+	p := NewPush(NewString("message"))
+	assert.Equal(t, "*1\r\n+message\r\n", string(ConvertToProtocol(p, RESP2).Encode()), "Pushes must become arrays in RESP2")
+	vs, _ := NewVerbatimString("txt", []byte("hi"))
+	assert.Equal(t, "$2\r\nhi\r\n", string(ConvertToProtocol(vs, RESP2).Encode()), "Verbatim strings must drop their format in RESP2")
+	a := NewAttribute(NewMap(), NewString("OK"))
+	assert.Equal(t, "+OK\r\n", string(ConvertToProtocol(a, RESP2).Encode()), "Attributes must be dropped in RESP2")
+}
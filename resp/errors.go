@@ -0,0 +1,87 @@
+package resp
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// errIncompleteFrame is returned by the parsers when the byte stream ends before
+// a full frame could be read. It is not a protocol error: the caller is expected
+// to wait for more bytes and try again.
+var errIncompleteFrame = errors.New("incomplete frame")
+
+// ProtocolError reports a byte stream that does not follow RESP. Once a stream
+// holds a protocol error there is no telling where the next frame starts, so
+// servers answer with the error and close the connection, like Redis does.
+type ProtocolError struct {
+	// Offset of the offending byte, counted from the start of the parsed input
+	Offset int
+	// Reason describes what is wrong, in the words Redis uses where it can
+	Reason string
+}
+
+// Error implements the error interface
+func (pe *ProtocolError) Error() string {
+	return "Protocol error: " + pe.Reason + " at offset " + strconv.Itoa(pe.Offset)
+}
+
+// RedisError returns the error reply Redis sends for a protocol error
+func (pe *ProtocolError) RedisError() RedisError {
+	return NewDefaultRedisError("Protocol error: " + pe.Reason)
+}
+
+// Create a new ProtocolError at the given offset
+func newProtocolError(offset int, reason string) *ProtocolError {
+	return &ProtocolError{Offset: offset, Reason: reason}
+}
+
+// Shift the offset of a protocol error by base, for errors found in the
+// middle of an aggregate
+func offsetBy(err error, base int) error {
+	if pe, ok := err.(*ProtocolError); ok {
+		pe.Offset += base
+	}
+	return err
+}
+
+// UnsupportedTypeError is returned by Marshal for Go values that have no RESP
+// equivalent, such as channels or functions
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+// Error implements the error interface
+func (e *UnsupportedTypeError) Error() string {
+	return "resp: unsupported type " + e.Type.String()
+}
+
+// UnmarshalTypeError is returned by Unmarshal when a RESP value cannot be
+// stored in the Go value it is decoded into
+type UnmarshalTypeError struct {
+	// Value describes the RESP value, like "bulk string abc"
+	Value string
+	Type  reflect.Type
+}
+
+// Error implements the error interface
+func (e *UnmarshalTypeError) Error() string {
+	return "resp: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+}
+
+// InvalidUnmarshalError is returned by Unmarshal when it is not given a
+// non-nil pointer to decode into
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+// Error implements the error interface
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "resp: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "resp: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "resp: Unmarshal(nil " + e.Type.String() + ")"
+}
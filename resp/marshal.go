@@ -0,0 +1,605 @@
+package resp
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Marshal and Unmarshal convert between Go values and RESP data types, in the
+// spirit of encoding/json. Struct fields are matched by name, which may be
+// changed with a tag such as `resp:"name"`. The tag option omitempty skips
+// empty fields when marshaling, and a tag of "-" skips the field altogether.
+
+// Name of the struct tag understood by Marshal and Unmarshal
+const tagName = "resp"
+
+var (
+	dataTypeType = reflect.TypeOf((*IDataType)(nil)).Elem()
+	bigIntType   = reflect.TypeOf(big.Int{})
+)
+
+// Marshal returns the RESP data type for v. Strings and byte slices become
+// bulk strings, integers become integers and floats doubles. Booleans, maps
+// and structs become their RESP3 types, which the Encoder turns into integers
+// and flat arrays for RESP2 connections. Other slices and arrays become
+// arrays. nil, nil pointers and nil byte slices become the null bulk string,
+// while other nil slices and maps are empty. Values that are an IDataType
+// already are returned as is.
+func Marshal(v interface{}) (IDataType, error) {
+	return marshalValue(reflect.ValueOf(v))
+}
+
+func marshalValue(rv reflect.Value) (IDataType, error) {
+	if !rv.IsValid() {
+		return EmptyBulkString, nil
+	}
+	if rv.Type().Implements(dataTypeType) {
+		if (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil() {
+			return EmptyBulkString, nil
+		}
+		return rv.Interface().(IDataType), nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return EmptyBulkString, nil
+		}
+		return marshalValue(rv.Elem())
+	case reflect.String:
+		return marshalBytes([]byte(rv.String()))
+	case reflect.Bool:
+		return NewBoolean(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInteger(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := rv.Uint()
+		if n > math.MaxInt64 {
+			return NewBigNumber(new(big.Int).SetUint64(n)), nil
+		}
+		return NewInteger(int64(n)), nil
+	case reflect.Float32, reflect.Float64:
+		return NewDouble(rv.Float()), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if rv.IsNil() {
+				return EmptyBulkString, nil
+			}
+			return marshalBytes(rv.Bytes())
+		}
+		return marshalArray(rv)
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return marshalBytes(b)
+		}
+		return marshalArray(rv)
+	case reflect.Map:
+		return marshalMap(rv)
+	case reflect.Struct:
+		if rv.Type() == bigIntType {
+			n := rv.Interface().(big.Int)
+			return NewBigNumber(&n), nil
+		}
+		return marshalStruct(rv)
+	}
+	return nil, &UnsupportedTypeError{Type: rv.Type()}
+}
+
+// Create a bulk string, which fails if b exceeds the maximum bulk length
+func marshalBytes(b []byte) (IDataType, error) {
+	bs, err := NewBulkStringFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// Marshal the items of a slice or array
+func marshalArray(rv reflect.Value) (IDataType, error) {
+	ra, _ := NewArray(rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item, err := marshalValue(rv.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		ra.SetItemAtIndex(i, item)
+	}
+	return ra, nil
+}
+
+// Marshal a map. Go maps are unordered, so the pairs are sorted by key to
+// keep replies stable
+func marshalMap(rv reflect.Value) (IDataType, error) {
+	keys := make([]IDataType, rv.Len())
+	values := make([]IDataType, rv.Len())
+	for i, k := range rv.MapKeys() {
+		key, err := marshalValue(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalValue(rv.MapIndex(k))
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+		values[i] = value
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return keys[order[i]].ToString() < keys[order[j]].ToString()
+	})
+	m := NewMap()
+	for _, i := range order {
+		m.Add(keys[i], values[i])
+	}
+	return m, nil
+}
+
+// Marshal the exported fields of a struct as a map, in field order
+func marshalStruct(rv reflect.Value) (IDataType, error) {
+	m := NewMap()
+	for _, f := range structFields(rv.Type()) {
+		fv := rv.Field(f.index)
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		value, err := marshalValue(fv)
+		if err != nil {
+			return nil, err
+		}
+		key, _ := NewBulkString(f.name)
+		m.Add(key, value)
+	}
+	return m, nil
+}
+
+// field describes how a struct field is marshaled
+type field struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+// List the exported fields of a struct type that are not skipped by their tag
+func structFields(t reflect.Type) []field {
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		tag := sf.Tag.Get(tagName)
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		f := field{name: parts[0], index: i}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for _, option := range parts[1:] {
+			if option == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// Check if a value counts as empty for omitempty, like encoding/json does
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// Unmarshal decodes dt into the value pointed to by v. Strings, bulk strings
+// and numbers may be decoded into Go strings and numbers interchangeably, as
+// long as the text holds a number of the right size. Arrays, sets and pushes
+// decode into slices, maps into Go maps and structs. Maps may also be sent as
+// flat arrays of alternating keys and values, the way RESP2 does. Nulls set v
+// to its zero value. Decoding into an empty interface yields string, int64,
+// float64, bool, *big.Int, []interface{} or map[string]interface{} values. If
+// dt is an error reply, it is returned as is.
+func Unmarshal(dt IDataType, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	if e, ok := dt.(RedisError); ok {
+		return e
+	}
+	return unmarshalValue(dt, rv.Elem())
+}
+
+func unmarshalValue(dt IDataType, rv reflect.Value) error {
+	dt = underlyingValue(dt)
+	if rv.Type() == dataTypeType {
+		// Keep the value as is
+		if dt == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+		} else {
+			rv.Set(reflect.ValueOf(dt))
+		}
+		return nil
+	}
+	if isNullDataType(dt) {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return unmarshalValue(dt, rv.Elem())
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			break
+		}
+		value := naturalValue(dt)
+		if value == nil {
+			break
+		}
+		rv.Set(reflect.ValueOf(value))
+		return nil
+	case reflect.String:
+		if isTextDataType(dt) {
+			rv.SetString(dt.ToString())
+			return nil
+		}
+	case reflect.Bool:
+		switch d := dt.(type) {
+		case Boolean:
+			rv.SetBool(d.GetBooleanValue())
+			return nil
+		case Integer:
+			rv.SetBool(d.GetIntegerValue() != 0)
+			return nil
+		case String, BulkString:
+			if b, err := strconv.ParseBool(dt.ToString()); err == nil {
+				rv.SetBool(b)
+				return nil
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := int64Of(dt); ok && !rv.OverflowInt(n) {
+			rv.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := uint64Of(dt); ok && !rv.OverflowUint(n) {
+			rv.SetUint(n)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := float64Of(dt); ok {
+			rv.SetFloat(f)
+			return nil
+		}
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if b, ok := bytesOf(dt); ok {
+				rv.SetBytes(append([]byte{}, b...))
+				return nil
+			}
+			break
+		}
+		items, ok := itemsOf(dt)
+		if !ok {
+			break
+		}
+		s := reflect.MakeSlice(rv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := unmarshalValue(item, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(s)
+		return nil
+	case reflect.Array:
+		items, ok := itemsOf(dt)
+		if !ok {
+			break
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(items) {
+				rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+				continue
+			}
+			if err := unmarshalValue(items[i], rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		keys, values, ok := pairsOf(dt)
+		if !ok {
+			break
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for i := range keys {
+			k := reflect.New(rv.Type().Key()).Elem()
+			if err := unmarshalValue(keys[i], k); err != nil {
+				return err
+			}
+			value := reflect.New(rv.Type().Elem()).Elem()
+			if err := unmarshalValue(values[i], value); err != nil {
+				return err
+			}
+			rv.SetMapIndex(k, value)
+		}
+		return nil
+	case reflect.Struct:
+		if rv.Type() == bigIntType {
+			if n, ok := bigIntOf(dt); ok {
+				rv.Set(reflect.ValueOf(*n))
+				return nil
+			}
+			break
+		}
+		keys, values, ok := pairsOf(dt)
+		if !ok {
+			break
+		}
+		fields := structFields(rv.Type())
+		for i := range keys {
+			f, ok := findField(fields, keys[i].ToString())
+			if !ok {
+				// Unknown keys are ignored
+				continue
+			}
+			if err := unmarshalValue(values[i], rv.Field(f.index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return &UnmarshalTypeError{Value: describeDataType(dt), Type: rv.Type()}
+}
+
+// Find the field for a key, preferring an exact match of its name
+func findField(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Dereference aggregates and drop attributes, which only describe the value
+func underlyingValue(dt IDataType) IDataType {
+	switch v := dt.(type) {
+	case *Array:
+		return *v
+	case *Map:
+		return *v
+	case *Set:
+		return *v
+	case *Push:
+		return *v
+	case Attribute:
+		return underlyingValue(v.value)
+	}
+	return dt
+}
+
+// Check for any of the null types
+func isNullDataType(dt IDataType) bool {
+	switch v := dt.(type) {
+	case nil, Null:
+		return true
+	case BulkString:
+		return v.IsNull()
+	case Array:
+		return v.IsNull()
+	}
+	return false
+}
+
+// Check for types whose text can be stored in a Go string
+func isTextDataType(dt IDataType) bool {
+	switch dt.(type) {
+	case String, BulkString, VerbatimString, Integer, Double, BigNumber:
+		return true
+	}
+	return false
+}
+
+// Get the raw bytes of a string type
+func bytesOf(dt IDataType) ([]byte, bool) {
+	switch v := dt.(type) {
+	case BulkString:
+		return v.Bytes(), true
+	case VerbatimString:
+		return v.Bytes(), true
+	case String:
+		return []byte(v.ToString()), true
+	}
+	return nil, false
+}
+
+// Get the value of an integer, or of a string holding one
+func int64Of(dt IDataType) (int64, bool) {
+	switch v := dt.(type) {
+	case Integer:
+		return v.GetIntegerValue(), true
+	case String, BulkString, BigNumber:
+		n, err := strconv.ParseInt(v.ToString(), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Get the value of a non-negative integer, or of a string holding one
+func uint64Of(dt IDataType) (uint64, bool) {
+	switch v := dt.(type) {
+	case Integer:
+		if v.GetIntegerValue() < 0 {
+			return 0, false
+		}
+		return uint64(v.GetIntegerValue()), true
+	case String, BulkString, BigNumber:
+		n, err := strconv.ParseUint(v.ToString(), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Get the value of a number, or of a string holding one
+func float64Of(dt IDataType) (float64, bool) {
+	switch v := dt.(type) {
+	case Double:
+		return v.GetDoubleValue(), true
+	case Integer:
+		return float64(v.GetIntegerValue()), true
+	case String, BulkString, BigNumber:
+		f, err := strconv.ParseFloat(v.ToString(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Get the value of a big number, or of an integer or string holding one
+func bigIntOf(dt IDataType) (*big.Int, bool) {
+	switch v := dt.(type) {
+	case BigNumber:
+		return v.GetBigIntValue(), true
+	case Integer, String, BulkString:
+		return new(big.Int).SetString(v.ToString(), 10)
+	}
+	return nil, false
+}
+
+// Get the items of an array, set or push
+func itemsOf(dt IDataType) ([]IDataType, bool) {
+	switch v := dt.(type) {
+	case Array:
+		return v.items, true
+	case Set:
+		return v.items, true
+	case Push:
+		return v.items, true
+	}
+	return nil, false
+}
+
+// Get the keys and values of a map, or of an array of alternating keys and
+// values as sent by RESP2
+func pairsOf(dt IDataType) ([]IDataType, []IDataType, bool) {
+	switch v := dt.(type) {
+	case Map:
+		return v.keys, v.values, true
+	case Array:
+		if len(v.items)%2 != 0 {
+			return nil, nil, false
+		}
+		keys := make([]IDataType, len(v.items)/2)
+		values := make([]IDataType, len(v.items)/2)
+		for i := range keys {
+			keys[i] = v.items[2*i]
+			values[i] = v.items[2*i+1]
+		}
+		return keys, values, true
+	}
+	return nil, nil, false
+}
+
+// Get the Go value that best represents dt, for decoding into interface{}
+func naturalValue(dt IDataType) interface{} {
+	switch v := underlyingValue(dt).(type) {
+	case String, BulkString, VerbatimString:
+		return v.ToString()
+	case Integer:
+		return v.GetIntegerValue()
+	case Double:
+		return v.GetDoubleValue()
+	case Boolean:
+		return v.GetBooleanValue()
+	case BigNumber:
+		return v.GetBigIntValue()
+	case RedisError:
+		return v
+	case Array, Set, Push:
+		items, _ := itemsOf(v)
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			if !isNullDataType(underlyingValue(item)) {
+				values[i] = naturalValue(item)
+			}
+		}
+		return values
+	case Map:
+		values := make(map[string]interface{}, len(v.keys))
+		for i, key := range v.keys {
+			var value interface{}
+			if !isNullDataType(underlyingValue(v.values[i])) {
+				value = naturalValue(v.values[i])
+			}
+			values[key.ToString()] = value
+		}
+		return values
+	}
+	return nil
+}
+
+// Describe the type of a value for error messages
+func describeDataType(dt IDataType) string {
+	switch dt.(type) {
+	case String:
+		return "simple string"
+	case RedisError:
+		return "error"
+	case Integer:
+		return "integer"
+	case BulkString:
+		return "bulk string"
+	case Array:
+		return "array"
+	case Map:
+		return "map"
+	case Set:
+		return "set"
+	case Double:
+		return "double"
+	case Boolean:
+		return "boolean"
+	case BigNumber:
+		return "big number"
+	case VerbatimString:
+		return "verbatim string"
+	case Push:
+		return "push"
+	}
+	return "value"
+}
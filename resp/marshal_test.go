@@ -0,0 +1,186 @@
+package resp
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type marshalUser struct {
+	Name    string            `resp:"name"`
+	Age     int               `resp:"age"`
+	Email   string            `resp:"email,omitempty"`
+	Tags    []string          `resp:"tags"`
+	Secret  string            `resp:"-"`
+	Extra   map[string]string `resp:"extra,omitempty"`
+	Score   float64
+	private int
+}
+
+func marshalAndEncode(t *testing.T, v interface{}, protocol int) string {
+	dt, err := Marshal(v)
+	assert.Nil(t, err)
+	return string(ConvertToProtocol(dt, protocol).Encode())
+}
+
+func TestMarshalScalars(t *testing.T) {
+	assert.Equal(t, "$3\r\nfoo\r\n", marshalAndEncode(t, "foo", RESP2))
+	assert.Equal(t, "$3\r\na\x00b\r\n", marshalAndEncode(t, []byte("a\x00b"), RESP2))
+	assert.Equal(t, ":-42\r\n", marshalAndEncode(t, -42, RESP2))
+	assert.Equal(t, ":7\r\n", marshalAndEncode(t, uint8(7), RESP2))
+	assert.Equal(t, ",1.5\r\n", marshalAndEncode(t, 1.5, RESP3))
+	assert.Equal(t, "#t\r\n", marshalAndEncode(t, true, RESP3))
+	assert.Equal(t, ":1\r\n", marshalAndEncode(t, true, RESP2))
+	assert.Equal(t, "(18446744073709551615\r\n", marshalAndEncode(t, uint64(math.MaxUint64), RESP3))
+	assert.Equal(t, "(12345678901234567890123\r\n", marshalAndEncode(t, bigFromString("12345678901234567890123"), RESP3))
+}
+
+func bigFromString(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func TestMarshalNil(t *testing.T) {
+	var p *int
+	var b []byte
+	var s []string
+	var m map[string]int
+	assert.Equal(t, "$-1\r\n", marshalAndEncode(t, nil, RESP2))
+	assert.Equal(t, "$-1\r\n", marshalAndEncode(t, p, RESP2))
+	assert.Equal(t, "$-1\r\n", marshalAndEncode(t, b, RESP2), "nil byte slices are null bulk strings")
+	assert.Equal(t, "*0\r\n", marshalAndEncode(t, s, RESP2), "nil slices are empty arrays")
+	assert.Equal(t, "%0\r\n", marshalAndEncode(t, m, RESP3), "nil maps are empty")
+}
+
+func TestMarshalAggregates(t *testing.T) {
+	assert.Equal(t, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", marshalAndEncode(t, []string{"a", "b"}, RESP2))
+	assert.Equal(t, "*2\r\n:1\r\n*1\r\n$-1\r\n", marshalAndEncode(t, []interface{}{1, []interface{}{nil}}, RESP2))
+	// Map keys are sorted
+	assert.Equal(t, "%2\r\n$1\r\na\r\n:1\r\n$1\r\nb\r\n:2\r\n", marshalAndEncode(t, map[string]int{"b": 2, "a": 1}, RESP3))
+	assert.Equal(t, "*4\r\n$1\r\na\r\n:1\r\n$1\r\nb\r\n:2\r\n", marshalAndEncode(t, map[string]int{"b": 2, "a": 1}, RESP2))
+	// IDataType values are kept as they are
+	assert.Equal(t, "*1\r\n+OK\r\n", marshalAndEncode(t, []IDataType{NewString("OK")}, RESP2))
+}
+
+func TestMarshalStruct(t *testing.T) {
+	u := marshalUser{Name: "ann", Age: 30, Tags: []string{"x"}, Secret: "s", Score: 0.5}
+	assert.Equal(t, "%4\r\n$4\r\nname\r\n$3\r\nann\r\n$3\r\nage\r\n:30\r\n$4\r\ntags\r\n*1\r\n$1\r\nx\r\n$5\r\nScore\r\n,0.5\r\n",
+		marshalAndEncode(t, &u, RESP3), "Tagged names, omitempty and skipped fields must be respected")
+}
+
+func TestMarshalUnsupported(t *testing.T) {
+	_, err := Marshal(make(chan int))
+	_, ok := err.(*UnsupportedTypeError)
+	assert.True(t, ok)
+	_, err = Marshal([]interface{}{func() {}})
+	assert.NotNil(t, err, "Unsupported items must fail the whole value")
+}
+
+func parseForUnmarshal(t *testing.T, s string) IDataType {
+	dt, _, err := parseReply([]byte(s))
+	assert.Nil(t, err)
+	return dt
+}
+
+func TestUnmarshalScalars(t *testing.T) {
+	var s string
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "$3\r\nfoo\r\n"), &s))
+	assert.Equal(t, "foo", s)
+	assert.Nil(t, Unmarshal(NewInteger(12), &s))
+	assert.Equal(t, "12", s)
+
+	var n int
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, ":-5\r\n"), &n))
+	assert.Equal(t, -5, n)
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "$2\r\n42\r\n"), &n), "Bulk strings holding numbers decode into ints")
+	assert.Equal(t, 42, n)
+
+	var small int8
+	err := Unmarshal(NewInteger(300), &small)
+	_, ok := err.(*UnmarshalTypeError)
+	assert.True(t, ok, "Values that overflow the target must fail")
+
+	var u uint
+	assert.NotNil(t, Unmarshal(NewInteger(-1), &u))
+
+	var f float64
+	assert.Nil(t, Unmarshal(NewDouble(2.5), &f))
+	assert.Equal(t, 2.5, f)
+
+	var b bool
+	assert.Nil(t, Unmarshal(NewInteger(1), &b))
+	assert.True(t, b)
+
+	var raw []byte
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "$3\r\na\x00b\r\n"), &raw))
+	assert.Equal(t, []byte("a\x00b"), raw)
+
+	var bn big.Int
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "(12345678901234567890123\r\n"), &bn))
+	assert.Equal(t, "12345678901234567890123", bn.String())
+
+	err = Unmarshal(parseForUnmarshal(t, "$3\r\nfoo\r\n"), &n)
+	assert.Equal(t, "resp: cannot unmarshal bulk string into Go value of type int", err.Error())
+}
+
+func TestUnmarshalNull(t *testing.T) {
+	s := "set"
+	p := &s
+	assert.Nil(t, Unmarshal(EmptyBulkString, &s))
+	assert.Equal(t, "", s)
+	assert.Nil(t, Unmarshal(EmptyNull, &p))
+	assert.Nil(t, p, "Nulls must set pointers to nil")
+	var dt IDataType
+	assert.Nil(t, Unmarshal(EmptyBulkString, &dt))
+	assert.Equal(t, EmptyBulkString, dt, "Decoding into IDataType keeps the value as is")
+}
+
+func TestUnmarshalAggregates(t *testing.T) {
+	var strs []string
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "*2\r\n$1\r\na\r\n$-1\r\n"), &strs))
+	assert.Equal(t, []string{"a", ""}, strs)
+
+	var fixed [3]int
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "~2\r\n:1\r\n:2\r\n"), &fixed))
+	assert.Equal(t, [3]int{1, 2, 0}, fixed)
+
+	var m map[string]int
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "%2\r\n+a\r\n:1\r\n+b\r\n:2\r\n"), &m))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, m)
+
+	// RESP2 sends maps as flat arrays
+	m = nil
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "*2\r\n$1\r\nc\r\n$1\r\n3\r\n"), &m))
+	assert.Equal(t, map[string]int{"c": 3}, m)
+
+	var value interface{}
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, "*3\r\n:1\r\n$1\r\nx\r\n%1\r\n+k\r\n,1.5\r\n"), &value))
+	assert.Equal(t, []interface{}{int64(1), "x", map[string]interface{}{"k": 1.5}}, value)
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	var u marshalUser
+	reply := parseForUnmarshal(t, "%5\r\n$4\r\nname\r\n$3\r\nann\r\n$3\r\nAGE\r\n:30\r\n$4\r\ntags\r\n*1\r\n$1\r\nx\r\n$5\r\nscore\r\n$3\r\n0.5\r\n$7\r\nunknown\r\n:1\r\n")
+	assert.Nil(t, Unmarshal(reply, &u))
+	assert.Equal(t, marshalUser{Name: "ann", Age: 30, Tags: []string{"x"}, Score: 0.5}, u,
+		"Keys must match names case-insensitively and unknown keys are ignored")
+
+	// Round trip through the encoder
+	dt, err := Marshal(u)
+	assert.Nil(t, err)
+	var decoded marshalUser
+	assert.Nil(t, Unmarshal(parseForUnmarshal(t, string(ConvertToProtocol(dt, RESP2).Encode())), &decoded))
+	assert.Equal(t, u, decoded)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var s string
+	err := Unmarshal(NewString("x"), s)
+	_, ok := err.(*InvalidUnmarshalError)
+	assert.True(t, ok, "Unmarshal needs a pointer")
+	assert.Equal(t, "resp: Unmarshal(nil)", Unmarshal(NewString("x"), nil).Error())
+	e := NewRedisError("WRONGTYPE", "Operation against a key holding the wrong kind of value")
+	assert.Equal(t, e, Unmarshal(e, &s), "Error replies are returned as errors")
+}
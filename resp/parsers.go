@@ -1,8 +1,9 @@
 package resp
 
 import (
+	"bytes"
 	"fmt"
-	"strconv"
+	"math"
 )
 
 const (
@@ -14,6 +15,16 @@ const (
 	bulkStringStartByte = byte('$')
 	arrayStartByte      = byte('*')
 	errorStartByte      = byte('-')
+	// RESP3
+	mapStartByte            = byte('%')
+	setStartByte            = byte('~')
+	doubleStartByte         = byte(',')
+	booleanStartByte        = byte('#')
+	nullStartByte           = byte('_')
+	bigNumberStartByte      = byte('(')
+	verbatimStringStartByte = byte('=')
+	attributeStartByte      = byte('|')
+	pushStartByte           = byte('>')
 )
 
 // ErrorCodes used by server to communicate with client.
@@ -23,255 +34,432 @@ const (
 )
 
 // The basic premise is as follows. The incoming message is parsed by an appropriate
-// parser. If any of the parsers panic, we recover and return RedisError serialized
-// to the client. Otherwise, we execute the command using CommandExecutor
+// parser. Each parser returns the parsed value along with the number of bytes it
+// consumed, or a *ProtocolError pointing at the offending byte. Otherwise, we
+// execute the command using CommandExecutor
 
-// Assert a non-empty byte stream or panic otherwise
-func assertNonEmptyStream(bytes []byte) {
+// Check for a non-empty byte stream
+func checkNonEmptyStream(bytes []byte) error {
 	if len(bytes) == 0 {
-		panic(NewRedisError(InvalidByteSeq, "Cannot parse empty byte stream"))
+		return newProtocolError(0, "cannot parse empty byte stream")
 	}
+	return nil
 }
 
-// Assert start start symbol of a byte stream (start byte) matches expected start byte (symbol)
-func assertStartSymbol(startByte byte, symbol byte) {
+// Check that start symbol of a byte stream (start byte) matches expected start byte (symbol)
+func checkStartSymbol(startByte byte, symbol byte) error {
 	if startByte != symbol {
-		panic(NewRedisError(InvalidByteSeq, fmt.Sprintf("Expected start byte to be %v, instead got %v", symbol, startByte)))
+		return newProtocolError(0, fmt.Sprintf("expected '%c', got '%c'", symbol, startByte))
 	}
+	return nil
+}
+
+// Check that the byte stream holds a full line, or signal that more bytes are needed
+func checkCompleteLine(stream []byte) error {
+	if bytes.IndexByte(stream, nlByte) == -1 {
+		return errIncompleteFrame
+	}
+	return nil
+}
+
+// Check that the byte stream holds the full header line of a command, which
+// like an inline command may not grow beyond MaxInlineCommandLength while
+// waiting for its newline. reason describes the header for Redis' error
+func checkCompleteHeader(stream []byte, reason string) error {
+	if bytes.IndexByte(stream, nlByte) == -1 {
+		if len(stream) > MaxInlineCommandLength {
+			return newProtocolError(MaxInlineCommandLength, reason)
+		}
+		return errIncompleteFrame
+	}
+	return nil
+}
+
+// Check that bytes is non-empty and starts with symbol
+func checkStart(bytes []byte, symbol byte) error {
+	if err := checkNonEmptyStream(bytes); err != nil {
+		return err
+	}
+	return checkStartSymbol(bytes[0], symbol)
 }
 
 // Utility function to read a byte stream until CRLF and return the number of bytes consumed
 // along with read bytes. This function can technically ignore the absence of a CR.
-func readUntilCRLF(bytes []byte, excludeFirstByte bool) (string, int) {
-	str := ""
-	var c byte
-	read := 0
-	i := 0
+// The returned line is a slice of bytes rather than a copy, so nothing is allocated.
+func readUntilCRLF(bytes []byte, excludeFirstByte bool) ([]byte, int) {
+	start := 0
 	if excludeFirstByte == true && len(bytes) > 0 {
-		i = 1
-		read = 1
-	}
-	// Initialize string
-	for ; i < len(bytes); i++ {
-		c = bytes[i]
-		read++
-		if c == nlByte {
+		start = 1
+	}
+	end := len(bytes)
+	read := len(bytes)
+	for i := start; i < len(bytes); i++ {
+		if bytes[i] == nlByte {
+			end = i
+			read = i + 1
 			break
 		}
-		if c != crByte {
-			str += string(c)
+	}
+	line := bytes[start:end]
+	if len(line) > 0 && line[len(line)-1] == crByte {
+		line = line[:len(line)-1]
+	}
+	return line, read
+}
+
+// Largest and smallest values of int, which lengths must fit in
+const (
+	maxInt = int(^uint(0) >> 1)
+	minInt = -maxInt - 1
+)
+
+// parseInt64 converts a decimal number with an optional sign to int64, like
+// strconv.ParseInt does, but straight from bytes so that no string is
+// allocated. It returns false if bytes is not a number or the number does not
+// fit in 64 bits.
+func parseInt64(bytes []byte) (int64, bool) {
+	negative := false
+	if len(bytes) > 0 && (bytes[0] == '-' || bytes[0] == '+') {
+		negative = bytes[0] == '-'
+		bytes = bytes[1:]
+	}
+	if len(bytes) == 0 {
+		return 0, false
+	}
+	// Accumulate negatively, since math.MinInt64 has no positive counterpart
+	var n int64
+	for _, c := range bytes {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		d := int64(c - '0')
+		if n < (math.MinInt64+d)/10 {
+			return 0, false
 		}
+		n = n*10 - d
+	}
+	if negative {
+		return n, true
+	}
+	if n == math.MinInt64 {
+		return 0, false
 	}
-	return str, read
+	return -n, true
 }
 
 // Parse a simple string from bytes and return parsed string and number of bytes consumed
-func parseSimpleString(bytes []byte) (String, int) {
-	assertNonEmptyStream(bytes)
-	assertStartSymbol(bytes[0], stringStartByte)
-	str, i := readUntilCRLF(bytes, true)
+func parseSimpleString(bytes []byte) (String, int, error) {
+	if err := checkStart(bytes, stringStartByte); err != nil {
+		return EmptyString, 0, err
+	}
+	line, i := readUntilCRLF(bytes, true)
 	// Return value and bytes read
-	return NewString(str), i
+	return NewString(string(line)), i, nil
 }
 
 // Parse an error message. Clients do not typically send error messages.
-func parseErrorMessage(bytes []byte) (RedisError, int) {
-	assertNonEmptyStream(bytes)
-	assertStartSymbol(bytes[0], errorStartByte)
-	var ecode string
-	var message string
-	var c byte
-	str := ""
-	i := 1
-	// Initialize string
-	for ; i < len(bytes); i++ {
-		c = bytes[i]
+// The error code runs up to the first space, the message is the rest of the line.
+func parseErrorMessage(bytes []byte) (RedisError, int, error) {
+	if err := checkStart(bytes, errorStartByte); err != nil {
+		return EmptyRedisError, 0, err
+	}
+	line, i := readUntilCRLF(bytes, true)
+	for space, c := range line {
 		if c == whitespaceByte {
-			// Check if ecode is set
-			if ecode == "" {
-				ecode = str
-				// Reset string
-				str = ""
-				continue
-			}
-		}
-		if c == nlByte {
-			if ecode == "" {
-				ecode = str
-				break
-			} else {
-				message = str
-				break
-			}
-		}
-		if c != crByte {
-			str += string(c)
+			// Return value and bytes read
+			return NewRedisError(string(line[:space]), string(line[space+1:])), i, nil
 		}
 	}
-	// Return value and bytes read
-	return NewRedisError(ecode, message), i + 1
+	return NewRedisError(string(line), ""), i, nil
 }
 
 // Parse a sequence of bytes as per Integer specification.
-func parseIntegers(bytes []byte) (Integer, int) {
-	assertNonEmptyStream(bytes)
-	assertStartSymbol(bytes[0], integerStartByte)
-	str, i := readUntilCRLF(bytes, true)
+func parseIntegers(bytes []byte) (Integer, int, error) {
+	if err := checkStart(bytes, integerStartByte); err != nil {
+		return EmptyInteger, 0, err
+	}
+	conv, i, err := parsePrefixedInteger(bytes, "invalid integer")
+	if err != nil {
+		return EmptyInteger, 0, err
+	}
+	return NewInteger(conv), i, nil
+}
+
+// Parse the signed 64 bit integer that follows the start byte of a line. The
+// start byte is skipped rather than rewritten, so the input is never modified.
+// reason is reported if the line does not hold an integer, or one that
+// overflows 64 bits.
+func parsePrefixedInteger(bytes []byte, reason string) (int64, int, error) {
+	line, i := readUntilCRLF(bytes, true)
 	// Return value and bytes read
-	conv, err := strconv.Atoi(str)
+	conv, ok := parseInt64(line)
+	if !ok {
+		return 0, 0, newProtocolError(1, reason)
+	}
+	return conv, i, nil
+}
+
+// Parse the length prefix of a bulk string or aggregate, which must fit in an
+// int. reason is reported otherwise.
+func parsePrefixedLength(bytes []byte, reason string) (int, int, error) {
+	length, i, err := parsePrefixedInteger(bytes, reason)
 	if err != nil {
-		panic(fmt.Sprintf("Invalid integer sequence supplied: %s", str))
+		return 0, 0, err
 	}
-	return NewInteger(conv), i
+	if length > int64(maxInt) || length < int64(minInt) {
+		return 0, 0, newProtocolError(1, reason)
+	}
+	return int(length), i, nil
 }
 
-// parse a sequence of bytes representing bulk string
-func parseBulkString(bytes []byte) (BulkString, int) {
-	assertNonEmptyStream(bytes)
-	assertStartSymbol(bytes[0], bulkStringStartByte)
-	// Convert start symbol to : and parse as integer
-	bytes[0] = integerStartByte
-	str := ""
-	isNullValue := false
-	respInt, read := parseIntegers(bytes)
-	read2 := 0
-	// This check is much faster than the length check in constructor.
-	// It is safer to fail here.
-	if respInt.GetIntegerValue() > (MaxBulkSizeLength) {
-		panic("Bulk string length exceeds maximum allowed size of " + MaxBulkSizeAsHumanReadableValue)
-	} else if respInt.GetIntegerValue() < -1 {
-		panic("Bulk string length must be greater than -1")
-	} else {
-		switch respInt.GetIntegerValue() {
-		case 0:
-			// Short circuit
-			break
-		case -1:
-			// Null string
-			isNullValue = true
-			break
-		default:
-			// Regular parse
-			bytes = bytes[read:]
-			str, read2 = readUntilCRLF(bytes, false)
-			if len(str) != respInt.GetIntegerValue() {
-				panic(fmt.Sprintf("Bulk string length %d does not match expected length of %d", len(str), respInt.GetIntegerValue()))
-			}
-			break
-		}
+// parse a sequence of bytes representing bulk string, of any length
+func parseBulkString(bytes []byte) (BulkString, int, error) {
+	return parseLimitedBulkString(bytes, math.MaxInt64)
+}
+
+// parse a bulk string of up to maxLength bytes. Longer ones are rejected
+// before their payload arrives
+func parseLimitedBulkString(bytes []byte, maxLength int64) (BulkString, int, error) {
+	if err := checkStart(bytes, bulkStringStartByte); err != nil {
+		return EmptyBulkString, 0, err
 	}
-	if isNullValue {
-		return NewNullBulkString(), read + read2
+	if err := checkCompleteLine(bytes); err != nil {
+		return EmptyBulkString, 0, err
 	}
-	bs, err := NewBulkString(str)
+	length, read, err := parsePrefixedLength(bytes, "invalid bulk length")
 	if err != nil {
-		panic(err)
+		return EmptyBulkString, 0, err
 	}
-	return bs, read + read2
+	if int64(length) > maxLength || length < -1 {
+		return EmptyBulkString, 0, newProtocolError(1, "invalid bulk length")
+	}
+	if length == -1 {
+		// Null string
+		return NewNullBulkString(), read, nil
+	}
+	payload, read2, err := readPayload(bytes[read:], length)
+	if err != nil {
+		return EmptyBulkString, 0, offsetBy(err, read)
+	}
+	return BulkString{value: payload}, read + read2, nil
+}
+
+// MaxArrayNestingDepth limits how deeply arrays may be nested inside each
+// other, so that a malicious stream cannot exhaust the stack
+const MaxArrayNestingDepth = 32
+
+// Read a payload of known length followed by CRLF, as found in bulk and
+// verbatim strings. Returns the payload and the number of bytes read. The
+// payload is a slice of bytes, capped so that appending to it cannot
+// overwrite whatever follows in the buffer
+func readPayload(bytes []byte, length int) ([]byte, int, error) {
+	// The declared length tells us exactly where the payload ends, so the
+	// payload itself is never scanned for delimiters. Even an empty
+	// payload is followed by CRLF.
+	// Compared this way round, huge lengths cannot overflow
+	if length > len(bytes)-2 {
+		return nil, 0, errIncompleteFrame
+	}
+	if bytes[length] != crByte || bytes[length+1] != nlByte {
+		return nil, 0, newProtocolError(length, "bulk string is not terminated by CRLF after its declared length")
+	}
+	return bytes[:length:length], length + 2, nil
 }
 
 // parseArray parses a sequence of bytes as per RESP array
-// specifications. Clients typically send commands as Array
-func parseArray(bytes []byte) (*Array, int) {
-	assertNonEmptyStream(bytes)
-	assertStartSymbol(bytes[0], arrayStartByte)
-	bytesRead := 0
-	// Reset to colon so we can parse integer
-	bytes[0] = integerStartByte
-	numberOfItems, n := parseIntegers(bytes)
-	bytesRead += n
-	// Create new Array
-	Array, err := NewArray(numberOfItems.GetIntegerValue())
+// specifications. Clients typically send commands as Array. Parsing stops once
+// the declared number of items has been read, so any trailing bytes are left
+// for the caller.
+func parseArray(bytes []byte) (*Array, int, error) {
+	return parseNestedArray(bytes, 1)
+}
 
+// parseNestedArray parses an array found at the given nesting depth, where
+// the outermost array is at depth 1
+func parseNestedArray(bytes []byte, depth int) (*Array, int, error) {
+	if err := checkStart(bytes, arrayStartByte); err != nil {
+		return nil, 0, err
+	}
+	if err := checkCompleteLine(bytes); err != nil {
+		return nil, 0, err
+	}
+	if depth > MaxArrayNestingDepth {
+		return nil, 0, newProtocolError(0, fmt.Sprintf("nesting depth exceeds limit of %d", MaxArrayNestingDepth))
+	}
+	numberOfItems, read, err := parsePrefixedLength(bytes, "invalid multibulk length")
+	if err != nil {
+		return nil, 0, err
+	}
+	if numberOfItems == -1 {
+		return NewNullArray(), read, nil
+	}
+	if numberOfItems < 0 {
+		return nil, 0, newProtocolError(1, "invalid multibulk length")
+	}
+	items, r, err := parseItems(bytes[read:], numberOfItems, depth)
 	if err != nil {
-		panic(err)
+		return nil, 0, offsetBy(err, read)
 	}
+	return &Array{items: items}, read + r, nil
+}
 
-	counter := 0
-	// Advance bytes
-	bytes = bytes[n:]
-	for {
-		if len(bytes) == 0 {
-			break
+// MaxMultibulkLength limits the number of arguments of a command, like Redis
+// does, so that a client cannot make the server allocate for arguments it
+// never sends
+const MaxMultibulkLength = 1024 * 1024
+
+// parseCommandArray parses a command sent as a RESP array. Unlike replies,
+// commands may only have up to MaxMultibulkLength arguments, and like in
+// Redis these must be bulk strings other than null, of up to maxBulkLength
+// bytes
+func parseCommandArray(bytes []byte, maxBulkLength int64) (*Array, int, error) {
+	if err := checkStart(bytes, arrayStartByte); err != nil {
+		return nil, 0, err
+	}
+	if err := checkCompleteHeader(bytes, "too big mbulk count string"); err != nil {
+		return nil, 0, err
+	}
+	numberOfItems, read, err := parsePrefixedLength(bytes, "invalid multibulk length")
+	if err != nil {
+		return nil, 0, err
+	}
+	if numberOfItems == -1 {
+		return NewNullArray(), read, nil
+	}
+	if numberOfItems < 0 || numberOfItems > MaxMultibulkLength {
+		return nil, 0, newProtocolError(1, "invalid multibulk length")
+	}
+	items := make([]IDataType, 0, preallocatedItems(numberOfItems))
+	for i := 0; i < numberOfItems; i++ {
+		if read == len(bytes) {
+			return nil, 0, errIncompleteFrame
 		}
-		if counter >= Array.GetNumberOfItems() {
-			panic(fmt.Sprintf("Invalid command stream. RESP Array index %d exceeds specified capacity of %s", counter+1, numberOfItems.ToString()))
+		if bytes[read] == bulkStringStartByte {
+			if err := checkCompleteHeader(bytes[read:], "too big bulk count string"); err != nil {
+				return nil, 0, offsetBy(err, read)
+			}
+		}
+		// Fails with expected '$' for anything else
+		bs, r, err := parseLimitedBulkString(bytes[read:], maxBulkLength)
+		if err != nil {
+			return nil, 0, offsetBy(err, read)
 		}
-		first := bytes[0]
-		var s IDataType
-		var r int
-		switch first {
-		case stringStartByte:
-			s, r = parseSimpleString(bytes)
-		case integerStartByte:
-			s, r = parseIntegers(bytes)
-		case bulkStringStartByte:
-			s, r = parseBulkString(bytes)
-		case errorStartByte:
-			s, r = parseErrorMessage(bytes)
-		default:
-			panic("Unknown start byte " + string(first))
+		if bs.IsNull() {
+			return nil, 0, newProtocolError(read+1, "invalid bulk length")
 		}
-		// Append to chunks
-		Array.SetItemAtIndex(counter, s)
-		// Advance by r bytes
-		bytes = bytes[r:]
-		// Add to bytes read
-		bytesRead += r
-		// Increase counter
-		counter++
-	}
-	return Array, bytesRead
+		items = append(items, bs)
+		read += r
+	}
+	return &Array{items: items}, read, nil
 }
 
-// Get the next array start byte starting from offset 1
-func getNextArrayStartByteIndex(bytes []byte) int {
-	for i := 1; i < len(bytes); i++ {
-		if bytes[i] == arrayStartByte {
-			return i
+// parseValue parses a single value of any type from the start of bytes. depth
+// is the nesting depth of the array holding the value, 0 at the top level.
+func parseValue(bytes []byte, depth int) (IDataType, int, error) {
+	if len(bytes) == 0 {
+		// The value has not arrived yet
+		return nil, 0, errIncompleteFrame
+	}
+	first := bytes[0]
+	switch first {
+	case bulkStringStartByte:
+		return parseBulkString(bytes)
+	case arrayStartByte:
+		ra, r, err := parseNestedArray(bytes, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return *ra, r, nil
+	case mapStartByte:
+		m, r, err := parseMap(bytes, depth+1)
+		if err != nil {
+			return nil, 0, err
 		}
+		return *m, r, nil
+	case setStartByte:
+		st, r, err := parseSet(bytes, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return *st, r, nil
+	case pushStartByte:
+		p, r, err := parsePush(bytes, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return *p, r, nil
+	case attributeStartByte:
+		return parseAttribute(bytes, depth+1)
+	case verbatimStringStartByte:
+		return parseVerbatimString(bytes)
+	}
+	// The remaining types fit on a single line
+	if err := checkCompleteLine(bytes); err != nil {
+		return nil, 0, err
 	}
-	return len(bytes)
+	switch first {
+	case stringStartByte:
+		return parseSimpleString(bytes)
+	case integerStartByte:
+		return parseIntegers(bytes)
+	case errorStartByte:
+		return parseErrorMessage(bytes)
+	case doubleStartByte:
+		return parseDouble(bytes)
+	case booleanStartByte:
+		return parseBoolean(bytes)
+	case nullStartByte:
+		return parseNull(bytes)
+	case bigNumberStartByte:
+		return parseBigNumber(bytes)
+	default:
+		return nil, 0, newProtocolError(0, fmt.Sprintf("unknown start byte '%c'", first))
+	}
+}
+
+// parseCommand parses a single command, in RESP or inline form, from the start
+// of bytes. It returns errIncompleteFrame if bytes does not yet hold the full
+// command. Bulk strings longer than maxBulkLength are protocol errors.
+func parseCommand(bytes []byte, maxBulkLength int64) (*Array, int, error) {
+	return parseClientCommand(bytes, maxBulkLength)
+}
+
+// parseReply parses a single value of any type, such as a reply sent by a
+// server. It returns errIncompleteFrame if bytes does not yet hold the full value.
+func parseReply(bytes []byte) (IDataType, int, error) {
+	return parseValue(bytes, 0)
 }
 
 // ParseRedisClientRequest takes in a sequence of bytes, and parses them
 // as sequential Array entries. Each command in a pipeline will form
-// a Array. This method catches internal panics, and returns top level
-// errors as RedisError. The caller can then check if the error is EmptyRedisError
-// and return appropriately
-func ParseRedisClientRequest(bytes []byte) (commands []Array, totalBytes int, finalErr RedisError) {
+// a Array. If the bytes do not follow the protocol, the commands parsed so far
+// are returned along with a *ProtocolError whose offset counts from the start
+// of bytes. If the bytes end in the middle of a command, the
+// complete commands are returned and totalBytes tells the caller how many bytes
+// were consumed. Commands may be sent inline as well, empty commands are
+// skipped like Redis does. The bulk strings of the commands are slices of bytes,
+// which is never modified, of up to MaxBulkSizeLength bytes.
+func ParseRedisClientRequest(bytes []byte) (commands []Array, totalBytes int, err error) {
 	commands = make([]Array, 0)
 	totalBytesRead := 0
-	finalErr = EmptyRedisError
-	// Top level panic recovery
-	defer func() {
-		if r := recover(); r != nil {
-			switch re := r.(type) {
-			case RedisError:
-				finalErr = re
-			case string:
-				finalErr = NewRedisError(DefaultErrorKeyword, re)
-			default:
-				fmt.Println(r)
-				// We don't know what caused this, so we return generic error
-				finalErr = NewDefaultRedisError(fmt.Sprint(r))
-			}
-		}
-	}()
 	for len(bytes) > 0 {
-		// For pipelines, we read until next arrayStartByte
-		asbIndex := getNextArrayStartByteIndex(bytes)
-		command, read := parseArray(bytes[0:asbIndex])
-		if read > 0 {
-			// Add command to commands list
-			commands = append(commands, *command)
-			// Reset bytest
-			bytes = bytes[read:]
-			totalBytesRead += read
-		} else {
+		// For pipelines, the declared lengths of each command tell us where
+		// the next one starts
+		command, read, err := parseClientCommand(bytes, MaxBulkSizeLength)
+		if err == errIncompleteFrame {
+			// Leave the partial command for the caller
 			break
 		}
+		if err != nil {
+			return commands, totalBytesRead, offsetBy(err, totalBytesRead)
+		}
+		// Add command to commands list
+		if command.GetNumberOfItems() > 0 {
+			commands = append(commands, *command)
+		}
+		// Reset bytest
+		bytes = bytes[read:]
+		totalBytesRead += read
 	}
-	// Execute commands on top of syncmap
-	return commands, totalBytesRead, finalErr
+	return commands, totalBytesRead, nil
 }
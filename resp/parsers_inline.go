@@ -0,0 +1,179 @@
+package resp
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Inline commands are plain lines of space separated arguments, as typed over
+// telnet or netcat: PING\r\n or SET foo "bar baz"\r\n. Redis accepts them
+// wherever a command is expected, as long as the line does not start with *.
+
+// MaxInlineCommandLength limits how long an inline command may grow while
+// waiting for its newline, like Redis does
+const MaxInlineCommandLength = 64 * 1024
+
+// parseClientCommand parses a single command sent by a client, either as
+// a RESP array or inline, whose bulk strings may hold up to maxBulkLength bytes
+func parseClientCommand(stream []byte, maxBulkLength int64) (*Array, int, error) {
+	if err := checkNonEmptyStream(stream); err != nil {
+		return nil, 0, err
+	}
+	if stream[0] == arrayStartByte {
+		return parseCommandArray(stream, maxBulkLength)
+	}
+	return parseInlineCommand(stream)
+}
+
+// parseInlineCommand parses a single line as an inline command. Every argument
+// becomes a bulk string, so inline commands look the same as RESP ones to the
+// command executor. An empty line parses to an empty array.
+func parseInlineCommand(stream []byte) (*Array, int, error) {
+	end := bytes.IndexByte(stream, nlByte)
+	if end == -1 {
+		if len(stream) > MaxInlineCommandLength {
+			return nil, 0, newProtocolError(MaxInlineCommandLength, "too big inline request")
+		}
+		return nil, 0, errIncompleteFrame
+	}
+	line := stream[:end]
+	// The CR is optional, netcat sends bare LFs
+	if len(line) > 0 && line[len(line)-1] == crByte {
+		line = line[:len(line)-1]
+	}
+	args, ok := splitInlineArgs(line)
+	if !ok {
+		return nil, 0, newProtocolError(0, "unbalanced quotes in request")
+	}
+	command, _ := NewArray(len(args))
+	for i, arg := range args {
+		// Arguments are bounded by the line length, well below the bulk limit
+		bs, _ := NewBulkStringFromBytes(arg)
+		command.SetItemAtIndex(i, bs)
+	}
+	return command, end + 1, nil
+}
+
+// SplitArgs splits a line into arguments following the quoting rules of
+// inline commands, which redis.conf uses as well. It returns false if the
+// quotes are unbalanced.
+func SplitArgs(line string) ([]string, bool) {
+	args, ok := splitInlineArgs([]byte(line))
+	if !ok {
+		return nil, false
+	}
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = string(arg)
+	}
+	return strs, true
+}
+
+// Check if c separates inline arguments
+func isInlineSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f'
+}
+
+// Check if c is a hexadecimal digit
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// splitInlineArgs splits a line into arguments the way redis-cli and the
+// Redis server do. Arguments may be double quoted, in which case \n, \r, \t,
+// \b, \a, \xHH and escaped characters are understood, or single quoted, in
+// which case only \' is. A closing quote must be followed by a space or the end
+// of the line. It returns false if the quotes are unbalanced.
+func splitInlineArgs(line []byte) ([][]byte, bool) {
+	args := make([][]byte, 0)
+	i := 0
+	for {
+		// Skip blanks
+		for i < len(line) && isInlineSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, true
+		}
+		inDoubleQuotes := false
+		inSingleQuotes := false
+		current := make([]byte, 0)
+		done := false
+		for !done {
+			if inDoubleQuotes {
+				if i == len(line) {
+					// Unterminated quotes
+					return nil, false
+				}
+				c := line[i]
+				if c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHexDigit(line[i+2]) && isHexDigit(line[i+3]) {
+					b, _ := strconv.ParseUint(string(line[i+2:i+4]), 16, 8)
+					current = append(current, byte(b))
+					i += 3
+				} else if c == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, line[i])
+					}
+				} else if c == '"' {
+					// Closing quote must be followed by a space or nothing at all
+					if i+1 < len(line) && !isInlineSpace(line[i+1]) {
+						return nil, false
+					}
+					done = true
+				} else {
+					current = append(current, c)
+				}
+			} else if inSingleQuotes {
+				if i == len(line) {
+					// Unterminated quotes
+					return nil, false
+				}
+				c := line[i]
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					current = append(current, '\'')
+				} else if c == '\'' {
+					// Closing quote must be followed by a space or nothing at all
+					if i+1 < len(line) && !isInlineSpace(line[i+1]) {
+						return nil, false
+					}
+					done = true
+				} else {
+					current = append(current, c)
+				}
+			} else {
+				if i == len(line) {
+					done = true
+					break
+				}
+				c := line[i]
+				switch {
+				case isInlineSpace(c):
+					done = true
+				case c == '"':
+					inDoubleQuotes = true
+				case c == '\'':
+					inSingleQuotes = true
+				default:
+					current = append(current, c)
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, current)
+	}
+}
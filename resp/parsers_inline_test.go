@@ -0,0 +1,64 @@
+package resp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertInlineArgs(t *testing.T, line string, expected ...string) {
+	args, ok := splitInlineArgs([]byte(line))
+	assert.True(t, ok, "Line must split without error: "+line)
+	actual := make([]string, len(args))
+	for i, arg := range args {
+		actual[i] = string(arg)
+	}
+	if expected == nil {
+		expected = []string{}
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestSplitInlineArgs(t *testing.T) {
+	assertInlineArgs(t, "")
+	assertInlineArgs(t, "   ")
+	assertInlineArgs(t, "PING", "PING")
+	assertInlineArgs(t, "  SET  foo\tbar ", "SET", "foo", "bar")
+	assertInlineArgs(t, `SET foo "bar baz"`, "SET", "foo", "bar baz")
+	assertInlineArgs(t, `SET foo "a\r\nb\x41\"\\"`, "SET", "foo", "a\r\nbA\"\\")
+	assertInlineArgs(t, `SET foo 'it\'s "raw" \n'`, "SET", "foo", `it's "raw" \n`)
+	assertInlineArgs(t, `SET foo ""`, "SET", "foo", "")
+
+	for _, line := range []string{`SET foo "bar`, `SET foo 'bar`, `SET foo "bar"baz`, `SET foo 'bar'baz`} {
+		_, ok := splitInlineArgs([]byte(line))
+		assert.False(t, ok, "Unbalanced quotes must be rejected: "+line)
+	}
+}
+
+func TestParseInlineCommand(t *testing.T) {
+	ra, read, _ := parseInlineCommand([]byte("SET foo \"bar baz\"\r\nGET foo\r\n"))
+	assert.Equal(t, 3, ra.GetNumberOfItems())
+	assert.Equal(t, 19, read, "Only the first line must be consumed")
+	_, ok := ra.GetItemAtIndex(2).(BulkString)
+	assert.True(t, ok, "Inline arguments must be bulk strings")
+	assert.Equal(t, "bar baz", ra.GetItemAtIndex(2).ToString())
+
+	// A bare LF ends the line as well
+	ra, read, _ = parseInlineCommand([]byte("PING\n"))
+	assert.Equal(t, "PING", ra.GetItemAtIndex(0).ToString())
+	assert.Equal(t, 5, read)
+
+	_, _, err := parseInlineCommand([]byte("SET foo \"bar\r\n"))
+	assertProtocolError(t, err, "parseInlineCommand fails on unbalanced quotes")
+	_, _, err = parseInlineCommand([]byte(strings.Repeat("a", MaxInlineCommandLength+1)))
+	assertProtocolError(t, err, "parseInlineCommand fails if the line grows too long without a newline")
+}
+
+func TestParseRedisClientRequestInline(t *testing.T) {
+	ras, read, err := ParseRedisClientRequest([]byte("PING\r\n\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\nGET k\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(ras), "Inline and RESP commands can be mixed, blank lines are skipped")
+	assert.Equal(t, 35, read)
+	assert.Equal(t, "k", ras[2].GetItemAtIndex(1).ToString())
+}
@@ -0,0 +1,223 @@
+package resp
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Parsers for the data types introduced by RESP3. Clients only send RESP2
+// arrays of bulk strings, so these are used to read server replies.
+
+// Parse the element count of an aggregate type, which must not be negative
+func parseAggregateLength(bytes []byte, symbol byte, depth int) (int, int, error) {
+	if err := checkStart(bytes, symbol); err != nil {
+		return 0, 0, err
+	}
+	if err := checkCompleteLine(bytes); err != nil {
+		return 0, 0, err
+	}
+	if depth > MaxArrayNestingDepth {
+		return 0, 0, newProtocolError(0, fmt.Sprintf("nesting depth exceeds limit of %d", MaxArrayNestingDepth))
+	}
+	length, read, err := parsePrefixedLength(bytes, "invalid aggregate length")
+	if err != nil {
+		return 0, 0, err
+	}
+	if length < 0 {
+		return 0, 0, newProtocolError(1, "invalid aggregate length")
+	}
+	return length, read, nil
+}
+
+// Parse numberOfPairs keys and values into m, and return the bytes read
+func parsePairs(bytes []byte, numberOfPairs int, m *Map, depth int) (int, error) {
+	bytesRead := 0
+	for i := 0; i < numberOfPairs; i++ {
+		key, r, err := parseValue(bytes, depth)
+		if err != nil {
+			return 0, offsetBy(err, bytesRead)
+		}
+		bytes = bytes[r:]
+		bytesRead += r
+		value, r, err := parseValue(bytes, depth)
+		if err != nil {
+			return 0, offsetBy(err, bytesRead)
+		}
+		bytes = bytes[r:]
+		bytesRead += r
+		m.Add(key, value)
+	}
+	return bytesRead, nil
+}
+
+// Aggregates get room for at most this many items up front. Their lengths
+// come from the other side, which may declare far more items than it sends
+const maxPreallocatedItems = 1024
+
+// The number of items to make room for in an aggregate of numberOfItems
+func preallocatedItems(numberOfItems int) int {
+	if numberOfItems > maxPreallocatedItems {
+		return maxPreallocatedItems
+	}
+	return numberOfItems
+}
+
+// Parse numberOfItems consecutive values, and return the bytes read
+func parseItems(bytes []byte, numberOfItems int, depth int) ([]IDataType, int, error) {
+	items := make([]IDataType, 0, preallocatedItems(numberOfItems))
+	bytesRead := 0
+	for i := 0; i < numberOfItems; i++ {
+		item, r, err := parseValue(bytes, depth)
+		if err != nil {
+			return nil, 0, offsetBy(err, bytesRead)
+		}
+		items = append(items, item)
+		bytes = bytes[r:]
+		bytesRead += r
+	}
+	return items, bytesRead, nil
+}
+
+// parseMap parses a sequence of bytes as per RESP3 map specification
+func parseMap(bytes []byte, depth int) (*Map, int, error) {
+	numberOfPairs, read, err := parseAggregateLength(bytes, mapStartByte, depth)
+	if err != nil {
+		return nil, 0, err
+	}
+	m := NewMap()
+	r, err := parsePairs(bytes[read:], numberOfPairs, m, depth)
+	if err != nil {
+		return nil, 0, offsetBy(err, read)
+	}
+	return m, read + r, nil
+}
+
+// parseSet parses a sequence of bytes as per RESP3 set specification
+func parseSet(bytes []byte, depth int) (*Set, int, error) {
+	numberOfItems, read, err := parseAggregateLength(bytes, setStartByte, depth)
+	if err != nil {
+		return nil, 0, err
+	}
+	items, r, err := parseItems(bytes[read:], numberOfItems, depth)
+	if err != nil {
+		return nil, 0, offsetBy(err, read)
+	}
+	return &Set{items: items}, read + r, nil
+}
+
+// parsePush parses a sequence of bytes as per RESP3 push specification
+func parsePush(bytes []byte, depth int) (*Push, int, error) {
+	numberOfItems, read, err := parseAggregateLength(bytes, pushStartByte, depth)
+	if err != nil {
+		return nil, 0, err
+	}
+	items, r, err := parseItems(bytes[read:], numberOfItems, depth)
+	if err != nil {
+		return nil, 0, offsetBy(err, read)
+	}
+	return NewPush(items...), read + r, nil
+}
+
+// parseAttribute parses the attribute pairs and the reply that follows them
+func parseAttribute(bytes []byte, depth int) (Attribute, int, error) {
+	numberOfPairs, read, err := parseAggregateLength(bytes, attributeStartByte, depth)
+	if err != nil {
+		return Attribute{}, 0, err
+	}
+	m := NewMap()
+	r, err := parsePairs(bytes[read:], numberOfPairs, m, depth)
+	if err != nil {
+		return Attribute{}, 0, offsetBy(err, read)
+	}
+	read += r
+	// The described reply counts as nested in the attribute, so that a chain
+	// of attributes cannot go deeper than MaxArrayNestingDepth either
+	value, r, err := parseValue(bytes[read:], depth)
+	if err != nil {
+		return Attribute{}, 0, offsetBy(err, read)
+	}
+	return NewAttribute(m, value), read + r, nil
+}
+
+// parseDouble parses a sequence of bytes as per RESP3 double specification
+func parseDouble(bytes []byte) (Double, int, error) {
+	if err := checkStart(bytes, doubleStartByte); err != nil {
+		return EmptyDouble, 0, err
+	}
+	line, read := readUntilCRLF(bytes, true)
+	// ParseFloat understands inf, -inf and nan as well
+	value, err := strconv.ParseFloat(string(line), 64)
+	if err != nil {
+		return EmptyDouble, 0, newProtocolError(1, "invalid double")
+	}
+	return NewDouble(value), read, nil
+}
+
+// parseBoolean parses a sequence of bytes as per RESP3 boolean specification
+func parseBoolean(bytes []byte) (Boolean, int, error) {
+	if err := checkStart(bytes, booleanStartByte); err != nil {
+		return EmptyBoolean, 0, err
+	}
+	line, read := readUntilCRLF(bytes, true)
+	switch string(line) {
+	case "t":
+		return NewBoolean(true), read, nil
+	case "f":
+		return NewBoolean(false), read, nil
+	default:
+		return EmptyBoolean, 0, newProtocolError(1, "invalid boolean")
+	}
+}
+
+// parseNull parses a sequence of bytes as per RESP3 null specification
+func parseNull(bytes []byte) (Null, int, error) {
+	if err := checkStart(bytes, nullStartByte); err != nil {
+		return EmptyNull, 0, err
+	}
+	line, read := readUntilCRLF(bytes, true)
+	if len(line) != 0 {
+		return EmptyNull, 0, newProtocolError(1, "invalid null")
+	}
+	return NewNull(), read, nil
+}
+
+// parseBigNumber parses a sequence of bytes as per RESP3 big number specification
+func parseBigNumber(bytes []byte) (BigNumber, int, error) {
+	if err := checkStart(bytes, bigNumberStartByte); err != nil {
+		return BigNumber{}, 0, err
+	}
+	line, read := readUntilCRLF(bytes, true)
+	value, ok := new(big.Int).SetString(string(line), 10)
+	if !ok {
+		return BigNumber{}, 0, newProtocolError(1, "invalid big number")
+	}
+	return BigNumber{value: value}, read, nil
+}
+
+// parseVerbatimString parses a sequence of bytes as per RESP3 verbatim string
+// specification. The payload starts with a three letter format and a colon
+func parseVerbatimString(bytes []byte) (VerbatimString, int, error) {
+	if err := checkStart(bytes, verbatimStringStartByte); err != nil {
+		return VerbatimString{}, 0, err
+	}
+	if err := checkCompleteLine(bytes); err != nil {
+		return VerbatimString{}, 0, err
+	}
+	length, read, err := parsePrefixedLength(bytes, "invalid verbatim string length")
+	if err != nil {
+		return VerbatimString{}, 0, err
+	}
+	if length < 4 {
+		return VerbatimString{}, 0, newProtocolError(1, "invalid verbatim string length")
+	}
+	payload, r, err := readPayload(bytes[read:], length)
+	if err != nil {
+		return VerbatimString{}, 0, offsetBy(err, read)
+	}
+	if payload[3] != ':' {
+		return VerbatimString{}, 0, newProtocolError(read+3, "verbatim string must start with a three letter format and a colon")
+	}
+	vs, _ := NewVerbatimString(string(payload[:3]), payload[4:])
+	return vs, read + r, nil
+}
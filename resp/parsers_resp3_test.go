@@ -0,0 +1,90 @@
+package resp
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMap(t *testing.T) {
+	m, read, _ := parseMap([]byte("%2\r\n+a\r\n:1\r\n+b\r\n*1\r\n:2\r\n+next\r\n"), 1)
+	assert.Equal(t, 2, m.GetNumberOfPairs())
+	assert.Equal(t, 24, read, "Bytes after the map must not be consumed")
+	_, v := m.GetPairAtIndex(1)
+	_, ok := v.(Array)
+	assert.True(t, ok, "Map values may be aggregates")
+
+	_, _, err := parseMap([]byte("%-1\r\n"), 1)
+	assertProtocolError(t, err, "parseMap fails on negative length")
+	_, _, err = parseMap([]byte("%1\r\n+a\r\n"), 1)
+	assert.Equal(t, errIncompleteFrame, err, "parseMap waits for the value of a pair to arrive")
+}
+
+func TestParseScalars(t *testing.T) {
+	d, read, _ := parseDouble([]byte(",3.25\r\n"))
+	assert.Equal(t, 3.25, d.GetDoubleValue())
+	assert.Equal(t, 7, read)
+	d, _, _ = parseDouble([]byte(",-inf\r\n"))
+	assert.True(t, math.IsInf(d.GetDoubleValue(), -1))
+	_, _, err := parseDouble([]byte(",abc\r\n"))
+	assertProtocolError(t, err)
+
+	b, read, _ := parseBoolean([]byte("#t\r\n"))
+	assert.Equal(t, true, b.GetBooleanValue())
+	assert.Equal(t, 4, read)
+	_, _, err = parseBoolean([]byte("#x\r\n"))
+	assertProtocolError(t, err)
+
+	_, read, _ = parseNull([]byte("_\r\n"))
+	assert.Equal(t, 3, read)
+
+	bn, _, _ := parseBigNumber([]byte("(-3492890328409238509324850943850943825024385\r\n"))
+	assert.Equal(t, "-3492890328409238509324850943850943825024385", bn.ToString())
+	_, _, err = parseBigNumber([]byte("(12a\r\n"))
+	assertProtocolError(t, err)
+
+	vs, read, _ := parseVerbatimString([]byte("=15\r\ntxt:Some string\r\n"))
+	assert.Equal(t, "txt", vs.GetFormat())
+	assert.Equal(t, "Some string", vs.ToString())
+	assert.Equal(t, 22, read)
+	_, _, err = parseVerbatimString([]byte("=3\r\ntxt\r\n"))
+	assertProtocolError(t, err, "Verbatim string must hold a format and a colon")
+}
+
+func TestParseResp3RoundTrip(t *testing.T) {
+	attrs := NewMap()
+	attrs.Add(NewString("key-popularity"), NewDouble(0.5))
+	inner := NewMap()
+	inner.Add(NewString("t"), NewBoolean(true))
+	inner.Add(NewString("n"), NewNull())
+	st := NewSet()
+	st.Add(NewString("member"))
+	inner.Add(NewString("s"), *st)
+	values := []IDataType{
+		*inner,
+		NewAttribute(attrs, NewInteger(1)),
+		*NewPush(NewString("invalidate"), *NewNullArray()),
+	}
+	for _, value := range values {
+		encoded := value.Encode()
+		parsed, read, err := parseReply(encoded)
+		assert.Nil(t, err)
+		assert.Equal(t, len(encoded), read)
+		assert.Equal(t, string(encoded), string(parsed.Encode()), "Encoded output must parse back to the same value")
+	}
+}
+
+func TestParseAttributeChain(t *testing.T) {
+	// Each attribute counts as a level of nesting, like an array
+	chain := strings.Repeat("|0\r\n", MaxArrayNestingDepth) + ":1\r\n"
+	value, read, err := parseReply([]byte(chain))
+	assert.Nil(t, err)
+	assert.Equal(t, len(chain), read)
+	assert.IsType(t, Attribute{}, value)
+	_, _, err = parseReply([]byte("|0\r\n" + chain))
+	assertProtocolError(t, err, "A chain of attributes must not go deeper than the limit")
+	_, _, err = parseReply([]byte(strings.Repeat("|0\r\n", 100000) + ":1\r\n"))
+	assertProtocolError(t, err)
+}
@@ -2,102 +2,131 @@ package resp
 
 import (
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestAssertByteStreamLength(t *testing.T) {
-	assert.Panics(t, func() {
-		assertNonEmptyStream([]byte{})
-	}, "Panics for empty stream")
-	assert.NotPanics(t, func() {
-		assertNonEmptyStream([]byte{'x'})
-	}, "Does not panics for non empty stream")
+// Assert that err is a protocol error, rather than an incomplete frame
+func assertProtocolError(t *testing.T, err error, msgAndArgs ...interface{}) *ProtocolError {
+	pe, ok := err.(*ProtocolError)
+	assert.True(t, ok, msgAndArgs...)
+	return pe
 }
 
-func TestAssertStartSymbol(t *testing.T) {
-	assert.Panics(t, func() {
-		assertStartSymbol(byte('+'), byte('-'))
-	}, "If start bytes do not match, the method must panic")
-	assert.NotPanics(t, func() {
-		assertStartSymbol(byte('+'), byte('+'))
-	}, "If start byte matches expected value, it does not panic")
+func TestCheckByteStreamLength(t *testing.T) {
+	assertProtocolError(t, checkNonEmptyStream([]byte{}), "Fails for empty stream")
+	assert.Nil(t, checkNonEmptyStream([]byte{'x'}), "Does not fail for non empty stream")
+}
+
+func TestCheckStartSymbol(t *testing.T) {
+	assertProtocolError(t, checkStartSymbol(byte('+'), byte('-')), "If start bytes do not match, the check must fail")
+	assert.Nil(t, checkStartSymbol(byte('+'), byte('+')), "If start byte matches expected value, it does not fail")
+}
+
+func TestCheckCompleteLine(t *testing.T) {
+	assert.Equal(t, errIncompleteFrame, checkCompleteLine([]byte(":42")), "A line without LF is incomplete")
+	assert.Nil(t, checkCompleteLine([]byte(":42\r\n")))
 }
 
 func TestReadUntilCRLF(t *testing.T) {
 	// Empty array
 	str, read := readUntilCRLF([]byte{}, true)
-	assert.Equal(t, str, "", "Empty byte stream must produce empty result")
+	assert.Equal(t, string(str), "", "Empty byte stream must produce empty result")
 	assert.Equal(t, read, 0, "Number of bytes read must be zero for empty array")
 
 	// Single character, excluding first byte no CRLF
 	str, read = readUntilCRLF([]byte{'$'}, true)
-	assert.Equal(t, str, "", "Excluding first byte must consume the very first byte and return empty")
+	assert.Equal(t, string(str), "", "Excluding first byte must consume the very first byte and return empty")
 	assert.Equal(t, read, 1, "Excluding first byte must return bytes read as 1")
 
 	// Single character, including first byte no CRLF
 	str, read = readUntilCRLF([]byte{'$'}, false)
-	assert.Equal(t, str, "$", "Including first byte must return the single character in byte array")
+	assert.Equal(t, string(str), "$", "Including first byte must return the single character in byte array")
 	assert.Equal(t, read, 1, "Including first byte must return bytes read as 1")
 
 	// Multiple bytes no CRLF
 	str, read = readUntilCRLF([]byte{'$', 'a', 'b'}, true)
-	assert.Equal(t, str, "ab", "Excluding first byte and reading multiple characters must return correct string")
+	assert.Equal(t, string(str), "ab", "Excluding first byte and reading multiple characters must return correct string")
 	assert.Equal(t, read, 3, "Excluding first byte and reading multiple characters must return correct bytes read")
 
 	// With CR only + exclude
 	str, read = readUntilCRLF([]byte{'\r'}, false)
-	assert.Equal(t, str, "", "Single CR byte array must return empty string excluding first byte")
+	assert.Equal(t, string(str), "", "Single CR byte array must return empty string excluding first byte")
 	assert.Equal(t, read, 1, "Single CR byte array must return bytes read as 1")
 
 	// With CR only + include
 	str, read = readUntilCRLF([]byte{'\r'}, true)
-	assert.Equal(t, str, "", "Single CR byte array must return empty string including first byte")
+	assert.Equal(t, string(str), "", "Single CR byte array must return empty string including first byte")
 	assert.Equal(t, read, 1, "Single CR byte array must return bytes read as 1")
 
 	// With LF only + exclude
 	str, read = readUntilCRLF([]byte{'\n'}, false)
-	assert.Equal(t, str, "", "Single LF byte array must return empty string excluding first byte")
+	assert.Equal(t, string(str), "", "Single LF byte array must return empty string excluding first byte")
 	assert.Equal(t, read, 1, "Single LF byte array must return bytes read as 1")
 
 	// With LF only + include
 	str, read = readUntilCRLF([]byte{'\n'}, true)
-	assert.Equal(t, str, "", "Single LF byte array must return empty string including first byte")
+	assert.Equal(t, string(str), "", "Single LF byte array must return empty string including first byte")
 	assert.Equal(t, read, 1, "Single LF byte array must return bytes read as 1")
 
 	// With CRLF
 	// With CR only + exclude
 	str, read = readUntilCRLF([]byte{'\r'}, false)
-	assert.Equal(t, str, "", "Single CR byte array must return empty string excluding first byte")
+	assert.Equal(t, string(str), "", "Single CR byte array must return empty string excluding first byte")
 	assert.Equal(t, read, 1, "Single CR byte array must return bytes read as 1")
 
 	// With CR only + exclude
 	str, read = readUntilCRLF([]byte("\r\n"), true)
-	assert.Equal(t, str, "", "CRLF byte array must return empty string exclude first byte")
+	assert.Equal(t, string(str), "", "CRLF byte array must return empty string exclude first byte")
 	assert.Equal(t, read, 2, "CRLF byte array must return bytes read as 2")
 
 	// With CR only + include
 	str, read = readUntilCRLF([]byte("\r\n"), false)
-	assert.Equal(t, str, "", "CRLF byte array must return empty string including first byte")
+	assert.Equal(t, string(str), "", "CRLF byte array must return empty string including first byte")
 	assert.Equal(t, read, 2, "CRLF byte array must return bytes read as 2")
 
 	// Ending with CRLF, excluding first byte
 	str, read = readUntilCRLF([]byte("$ab\r\n"), true)
-	assert.Equal(t, str, "ab", "Byte array excluding first byte and ending with CRLF must return string in between")
+	assert.Equal(t, string(str), "ab", "Byte array excluding first byte and ending with CRLF must return string in between")
 	assert.Equal(t, read, 5)
 
 	// Ending with CRLF, including first byte
 	str, read = readUntilCRLF([]byte("$ab\r\n"), false)
-	assert.Equal(t, str, "$ab", "Byte array excluding first byte and ending with CRLF must return string in between plus starting byte")
+	assert.Equal(t, string(str), "$ab", "Byte array excluding first byte and ending with CRLF must return string in between plus starting byte")
 	assert.Equal(t, read, 5)
 
 	// Characters after CRLF
 	str, read = readUntilCRLF([]byte("$ab\r\ncd"), true)
-	assert.Equal(t, str, "ab", "Characters after CRLF are ignored")
+	assert.Equal(t, string(str), "ab", "Characters after CRLF are ignored")
 	assert.Equal(t, read, 5, "Bytes after CRLF are ignored")
 }
 
+func TestParseInt64(t *testing.T) {
+	for input, expected := range map[string]int64{"0": 0, "42": 42, "-42": -42, "+7": 7, "007": 7} {
+		n, ok := parseInt64([]byte(input))
+		assert.True(t, ok, input)
+		assert.Equal(t, expected, n, input)
+	}
+	n, ok := parseInt64([]byte("9223372036854775807"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(math.MaxInt64), n)
+	n, ok = parseInt64([]byte("-9223372036854775808"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(math.MinInt64), n)
+	for _, input := range []string{"", "-", "+", "4x", " 4", "4 ", "1.5", "99999999999999999999", "-99999999999999999999"} {
+		_, ok = parseInt64([]byte(input))
+		assert.False(t, ok, "%q is not an int64", input)
+	}
+	// One past the limits
+	_, ok = parseInt64([]byte("9223372036854775808"))
+	assert.False(t, ok)
+	_, ok = parseInt64([]byte("-9223372036854775809"))
+	assert.False(t, ok)
+}
+
 func assertStringValueAndBytesRead(t *testing.T, str String, read int, readExpected int) {
 	assert.Equal(t, str.ToString(), "ab", fmt.Sprintf("Simple string value should be: %s, got: %s.", "ab", str.ToString()))
 	assert.Equal(t, read, readExpected, fmt.Sprintf("Should return correct number of bytes read. Got: %d, Expected: %d", read, readExpected))
@@ -105,22 +134,20 @@ func assertStringValueAndBytesRead(t *testing.T, str String, read int, readExpec
 
 func TestParseSimpleString(t *testing.T) {
 	// Empty string
-	assert.Panics(t, func() {
-		parseSimpleString([]byte{})
-	}, "Empty stream causes parseSimpleString to panic")
+	_, _, err := parseSimpleString([]byte{})
+	assertProtocolError(t, err, "Empty stream causes parseSimpleString to fail")
 
 	// Wrong data type sent in
-	assert.Panics(t, func() {
-		parseSimpleString([]byte(":ab\r\n"))
-	}, "parseSimpleStrings panics if starting byte does not match expected symbol +")
+	_, _, err = parseSimpleString([]byte(":ab\r\n"))
+	assertProtocolError(t, err, "parseSimpleStrings fails if starting byte does not match expected symbol +")
 
-	str, read := parseSimpleString([]byte("+ab\r\n"))
+	str, read, _ := parseSimpleString([]byte("+ab\r\n"))
 	assertStringValueAndBytesRead(t, str, read, 5)
 	// Try with more inputs after \n
-	str, read = parseSimpleString([]byte("+ab\r\n-ER\r\n"))
+	str, read, _ = parseSimpleString([]byte("+ab\r\n-ER\r\n"))
 	assertStringValueAndBytesRead(t, str, read, 5)
 	// With no \r
-	str, read = parseSimpleString([]byte("+ab\n"))
+	str, read, _ = parseSimpleString([]byte("+ab\n"))
 	assertStringValueAndBytesRead(t, str, read, 4)
 }
 
@@ -132,138 +159,172 @@ func assertErrorCodeMessageAndBytesRead(t *testing.T, e RedisError, eExpected Re
 func TestParseErrorMessage(t *testing.T) {
 
 	// Empty array
-	assert.Panics(t, func() {
-		parseErrorMessage([]byte{})
-	}, "Empty stream causes parseErrorMessage to panic")
+	_, _, err := parseErrorMessage([]byte{})
+	assertProtocolError(t, err, "Empty stream causes parseErrorMessage to fail")
 
 	// Wrong data type sent in
-	assert.Panics(t, func() {
-		parseErrorMessage([]byte(":ab\r\n"))
-	}, "parseErrorMessage panics if starting byte does not match expected symbol -")
+	_, _, err = parseErrorMessage([]byte(":ab\r\n"))
+	assertProtocolError(t, err, "parseErrorMessage fails if starting byte does not match expected symbol -")
 
-	e, read := parseErrorMessage([]byte{'-', 'E', 'R', 'R', '\r', '\n'})
+	e, read, _ := parseErrorMessage([]byte{'-', 'E', 'R', 'R', '\r', '\n'})
 	assertErrorCodeMessageAndBytesRead(t, e, NewRedisError("ERR", ""), read, 6)
 	// Try with more inputs after \n
-	e, read = parseErrorMessage([]byte{'-', 'E', 'R', 'R', '\r', '\n', '+', 'a', 'b', '\r', '\n'})
+	e, read, _ = parseErrorMessage([]byte{'-', 'E', 'R', 'R', '\r', '\n', '+', 'a', 'b', '\r', '\n'})
 	assertErrorCodeMessageAndBytesRead(t, e, NewRedisError("ERR", ""), read, 6)
 	// With no \r
-	e, read = parseErrorMessage([]byte{'-', 'E', 'R', 'R', '\n'})
+	e, read, _ = parseErrorMessage([]byte{'-', 'E', 'R', 'R', '\n'})
 	assertErrorCodeMessageAndBytesRead(t, e, NewRedisError("ERR", ""), read, 5)
 	// With message
-	e, read = parseErrorMessage([]byte{'-', 'E', 'R', 'R', ' ', 'm', 'o', 'o', '\r', '\n'})
+	e, read, _ = parseErrorMessage([]byte{'-', 'E', 'R', 'R', ' ', 'm', 'o', 'o', '\r', '\n'})
 	assertErrorCodeMessageAndBytesRead(t, e, NewRedisError("ERR", "moo"), read, 10)
 
 	// Custom error message
-	e, read = parseErrorMessage([]byte("-WRONGTYPE foobar\r\n"))
+	e, read, _ = parseErrorMessage([]byte("-WRONGTYPE foobar\r\n"))
 	assertErrorCodeMessageAndBytesRead(t, e, NewRedisError("WRONGTYPE", "foobar"), read, 19)
+
+	// Message keeps its own spaces
+	e, _, _ = parseErrorMessage([]byte("-ERR unknown command 'foo'\r\n"))
+	assert.Equal(t, NewRedisError("ERR", "unknown command 'foo'"), e)
 }
 
 func TestParseIntegers(t *testing.T) {
 
 	// Empty array
-	assert.Panics(t, func() {
-		parseIntegers([]byte{})
-	}, "Empty stream causes parseIntegers to panic")
+	_, _, err := parseIntegers([]byte{})
+	assertProtocolError(t, err, "Empty stream causes parseIntegers to fail")
 
 	// Wrong data type sent in
-	assert.Panics(t, func() {
-		parseIntegers([]byte("?53\r\n"))
-	}, "parseIntegers panics if starting byte does not match expected symbol :")
+	_, _, err = parseIntegers([]byte("?53\r\n"))
+	assertProtocolError(t, err, "parseIntegers fails if starting byte does not match expected symbol :")
 
 	// With no CRLF
-	i, read := parseIntegers([]byte(":42"))
-	assert.Equal(t, i.GetIntegerValue(), 42)
+	i, read, _ := parseIntegers([]byte(":42"))
+	assert.Equal(t, i.GetIntegerValue(), int64(42))
 	assert.Equal(t, read, 3)
 
 	// With CRLF
-	i, read = parseIntegers([]byte(":42\r\n"))
-	assert.Equal(t, i.GetIntegerValue(), 42)
+	i, read, _ = parseIntegers([]byte(":42\r\n"))
+	assert.Equal(t, i.GetIntegerValue(), int64(42))
 	assert.Equal(t, read, 5)
 
 	// Negative integer
-	i, read = parseIntegers([]byte(":-42\r\n"))
-	assert.Equal(t, i.GetIntegerValue(), -42)
+	i, read, _ = parseIntegers([]byte(":-42\r\n"))
+	assert.Equal(t, i.GetIntegerValue(), int64(-42))
 	assert.Equal(t, read, 6)
 
 	// Invalid integer
-	assert.Panics(t, func() {
-		parseIntegers([]byte(":ab\r\n"))
-	}, "Invalid integer will cause parseIntegers to panic")
+	_, _, err = parseIntegers([]byte(":ab\r\n"))
+	assertProtocolError(t, err, "Invalid integer will cause parseIntegers to fail")
+
+	// Full 64 bit range
+	i, _, _ = parseIntegers([]byte(":1700000000000\r\n"))
+	assert.Equal(t, int64(1700000000000), i.GetIntegerValue(), "Epoch milliseconds must not be truncated")
+	i, _, _ = parseIntegers([]byte(":-9223372036854775808\r\n"))
+	assert.Equal(t, int64(math.MinInt64), i.GetIntegerValue())
+
+	// Overflow
+	_, _, err = parseIntegers([]byte(":9223372036854775808\r\n"))
+	pe := assertProtocolError(t, err, "Integers beyond 64 bits must fail")
+	assert.Equal(t, "invalid integer", pe.Reason)
 }
 
 func TestParseBulkString(t *testing.T) {
 
 	// Empty array
-	assert.Panics(t, func() {
-		parseBulkString([]byte{})
-	}, "Empty stream causes parseBulkString to panic")
+	_, _, err := parseBulkString([]byte{})
+	assertProtocolError(t, err, "Empty stream causes parseBulkString to fail")
 
 	// Wrong data type sent in
-	assert.Panics(t, func() {
-		parseBulkString([]byte("?2\r\n"))
-	}, "parseBulkString panics if starting byte does not match expected symbol $")
+	_, _, err = parseBulkString([]byte("?2\r\n"))
+	assertProtocolError(t, err, "parseBulkString fails if starting byte does not match expected symbol $")
 
 	// Size greater than allowed size
-	assert.Panics(t, func() {
-		parseBulkString([]byte(fmt.Sprintf("$%d\r\n", MaxBulkSizeLength+1)))
-	}, "parseBulkString cannot parse bulk strings greater than "+MaxBulkSizeAsHumanReadableValue)
+	_, _, err = parseLimitedBulkString([]byte(fmt.Sprintf("$%d\r\n", MaxBulkSizeLength+1)), MaxBulkSizeLength)
+	assertProtocolError(t, err, "parseBulkString cannot parse bulk strings greater than "+MaxBulkSizeAsHumanReadableValue)
+
+	// Lengths near the largest int must not overflow
+	_, _, err = readPayload([]byte("ab\r\n"), maxInt)
+	assert.Equal(t, errIncompleteFrame, err, "readPayload must wait for huge payloads")
 
 	// Size less than 0
-	assert.Panics(t, func() {
-		parseBulkString([]byte("$-4\r\n"))
-	}, "parseBulkString cannot parse bulk strings with negative size")
+	_, _, err = parseBulkString([]byte("$-4\r\n"))
+	assertProtocolError(t, err, "parseBulkString cannot parse bulk strings with negative size")
 
 	// Correct parse
-	bs, read := parseBulkString([]byte("$2\r\nab\r\n"))
+	bs, read, _ := parseBulkString([]byte("$2\r\nab\r\n"))
 	assert.Equal(t, bs.ToString(), "ab", "Bulk string value must match expected string")
 	assert.Equal(t, read, 8, "Bytes read must be correct for bulkstring")
 	assert.Equal(t, bs.IsNull(), false, "Proper bulk string must not return true as nil bulk string")
 
 	// Nil bulk string
-	bs, read = parseBulkString([]byte("$-1\r\n"))
+	bs, read, _ = parseBulkString([]byte("$-1\r\n"))
 	assert.Equal(t, bs.IsNull(), true, "Nil bulk string must return true with IsNull method")
 
 	// Entries after CRLF are ignored
-	bs, read = parseBulkString([]byte("$2\r\nab\r\n:42\r\n"))
+	bs, read, _ = parseBulkString([]byte("$2\r\nab\r\n:42\r\n"))
 	assert.Equal(t, bs.ToString(), "ab")
 	assert.Equal(t, read, 8)
 	assert.Equal(t, bs.IsNull(), false)
 
 	// Empty bulk string (not nil bulk string)
-	bs, read = parseBulkString([]byte("$0\r\n"))
+	bs, read, _ = parseBulkString([]byte("$0\r\n\r\n"))
 	assert.Equal(t, bs.ToString(), "", "Empty bulk string must have value `\"`")
-	assert.Equal(t, read, 4, "Bytes read must be correct for empty bulkstring")
+	assert.Equal(t, read, 6, "Bytes read must include the CRLF after an empty payload")
 	assert.Equal(t, bs.IsNull(), false, "Empty bulk string must not return true for IsNull method")
 
-	// Panics if bulk string length does not match string length
-	assert.Panics(t, func() {
-		parseBulkString([]byte("$2\r\na\r\n"))
-	}, "parseBulkString panics if the length of bulk string does not match expected length")
+	// A payload shorter than its declared length has not fully arrived yet
+	_, _, err = parseBulkString([]byte("$2\r\na\r"))
+	assert.Equal(t, errIncompleteFrame, err, "parseBulkString waits for the full length of the bulk string")
+	_, _, err = parseBulkString([]byte("$2\r\nabc\r\n"))
+	pe := assertProtocolError(t, err, "parseBulkString fails if the payload is not terminated right after the declared length")
+	assert.Equal(t, 6, pe.Offset, "Offset must point at the byte after the declared length")
+
+	// Payload containing RESP symbols is read by length only
+	bs, read, _ = parseBulkString([]byte("$5\r\na*b:c\r\n"))
+	assert.Equal(t, bs.ToString(), "a*b:c")
+	assert.Equal(t, read, 11)
+
+	// Binary payloads with embedded CRLF survive unchanged
+	bs, read, _ = parseBulkString([]byte("$6\r\na\r\nb\x00\xff\r\n"))
+	assert.Equal(t, bs.Bytes(), []byte("a\r\nb\x00\xff"))
+	assert.Equal(t, read, 12)
+
+	// Parsed payload is a slice of the input, which is left untouched
+	input := []byte("$2\r\nab\r\n$1\r\nc\r\n")
+	bs, _, _ = parseBulkString(input)
+	assert.Equal(t, string(input), "$2\r\nab\r\n$1\r\nc\r\n", "Parsing must not modify the input")
+	input[4] = 'x'
+	assert.Equal(t, bs.ToString(), "xb", "Bulk string payload is sliced from the input")
+	// Appending to the payload must not overwrite the rest of the input
+	_ = append(bs.Bytes(), 'z')
+	assert.Equal(t, string(input[6:]), "\r\n$1\r\nc\r\n")
 }
 
 func TestParseArray(t *testing.T) {
 	// Empty array
-	assert.Panics(t, func() {
-		parseArray([]byte{})
-	}, "Empty stream causes parseArray to panic")
+	_, _, err := parseArray([]byte{})
+	assertProtocolError(t, err, "Empty stream causes parseArray to fail")
 
 	// Wrong data type sent in
-	assert.Panics(t, func() {
-		parseArray([]byte{})
-	}, "parseArray panics if starting byte does not match expected symbol *")
+	_, _, err = parseArray([]byte{})
+	assertProtocolError(t, err, "parseArray fails if starting byte does not match expected symbol *")
 
 	// If number of elements is invalid, it panics
-	assert.Panics(t, func() {
-		parseArray([]byte("*-1\r\n"))
-	}, "parseArray panics if number of items is less than 0")
+	_, _, err = parseArray([]byte("*-2\r\n"))
+	assertProtocolError(t, err, "parseArray fails if number of items is less than -1")
+
+	// Null array
+	ra, read, _ := parseArray([]byte("*-1\r\n"))
+	assert.Equal(t, ra.IsNull(), true, "Null array must return true with IsNull method")
+	assert.Equal(t, read, 5)
 
 	// Empty RESP array
-	ra, read := parseArray([]byte("*0\r\n"))
+	ra, read, _ = parseArray([]byte("*0\r\n"))
 	assert.Equal(t, ra.GetNumberOfItems(), 0, "Empty Array must have zero length")
 	assert.Equal(t, read, 4)
 
 	// Size 1 RESP array
-	ra, read = parseArray([]byte("*1\r\n:42\r\n"))
+	ra, read, _ = parseArray([]byte("*1\r\n:42\r\n"))
 	assert.Equal(t, ra.GetNumberOfItems(), 1)
 	assert.Equal(t, read, 9)
 	switch ra.GetItemAtIndex(0).(type) {
@@ -274,7 +335,7 @@ func TestParseArray(t *testing.T) {
 	}
 
 	// Mixed array
-	ra, read = parseArray([]byte("*2\r\n:42\r\n+ab\r\n"))
+	ra, read, _ = parseArray([]byte("*2\r\n:42\r\n+ab\r\n"))
 	assert.Equal(t, ra.GetNumberOfItems(), 2)
 	assert.Equal(t, read, 14)
 	switch ra.GetItemAtIndex(1).(type) {
@@ -284,8 +345,164 @@ func TestParseArray(t *testing.T) {
 		assert.Fail(t, "Expected second item of Array to be String")
 	}
 
-	// Panics if one of the elements is invalid
-	assert.Panics(t, func() {
-		parseArray([]byte("*2\r\n:ii\r\n+ab\r\n"))
-	})
+	// Fails if one of the elements is invalid
+	_, _, err = parseArray([]byte("*2\r\n:ii\r\n+ab\r\n"))
+	pe := assertProtocolError(t, err)
+	assert.Equal(t, 5, pe.Offset, "Offset must count from the start of the outer array")
+	assert.Equal(t, "invalid integer", pe.Reason)
+}
+
+func TestParseHugeLengths(t *testing.T) {
+	// Lengths come from the other side, nothing is allocated for them
+	_, _, err := parseReply([]byte("*9000000000000000\r\n"))
+	assert.Equal(t, errIncompleteFrame, err, "Huge arrays must wait for their items")
+	_, _, err = parseReply([]byte("~9000000000000000\r\n"))
+	assert.Equal(t, errIncompleteFrame, err, "Huge sets must wait for their items")
+
+	_, _, err = parseCommand([]byte("*9000000000000000\r\n"), MaxBulkSizeLength)
+	pe := assertProtocolError(t, err)
+	assert.Equal(t, "invalid multibulk length", pe.Reason)
+	_, _, err = parseCommand([]byte(fmt.Sprintf("*%d\r\n", MaxMultibulkLength+1)), MaxBulkSizeLength)
+	assertProtocolError(t, err, "Commands must not have more than MaxMultibulkLength arguments")
+	_, _, err = parseCommand([]byte(fmt.Sprintf("*%d\r\n", MaxMultibulkLength)), MaxBulkSizeLength)
+	assert.Equal(t, errIncompleteFrame, err)
+}
+
+func TestParseCommandArray(t *testing.T) {
+	command, read, err := parseCommand([]byte("*2\r\n$3\r\nGET\r\n$1\r\nk\r\n"), MaxBulkSizeLength)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, command.GetNumberOfItems())
+	assert.Equal(t, 20, read)
+
+	// Like Redis, commands only hold bulk strings
+	invalid := map[string]string{
+		"*1\r\n*1\r\n$1\r\na\r\n": "expected '$', got '*'",
+		"*1\r\n:1\r\n":            "expected '$', got ':'",
+		"*1\r\n%0\r\n":            "expected '$', got '%'",
+		"*1\r\n$-1\r\n":           "invalid bulk length",
+	}
+	for stream, reason := range invalid {
+		_, _, err = parseCommand([]byte(stream), MaxBulkSizeLength)
+		pe := assertProtocolError(t, err)
+		assert.Equal(t, reason, pe.Reason, stream)
+	}
+	_, _, err = parseCommand([]byte("*2\r\n$1\r\na\r\n"), MaxBulkSizeLength)
+	assert.Equal(t, errIncompleteFrame, err, "Commands must wait for their arguments")
+
+	// Header lines may only grow so long while waiting for their newline
+	digits := strings.Repeat("1", MaxInlineCommandLength)
+	_, _, err = parseCommand([]byte("*"+digits[1:]), MaxBulkSizeLength)
+	assert.Equal(t, errIncompleteFrame, err)
+	_, _, err = parseCommand([]byte("*"+digits), MaxBulkSizeLength)
+	pe := assertProtocolError(t, err)
+	assert.Equal(t, "too big mbulk count string", pe.Reason)
+	_, _, err = parseCommand([]byte("*1\r\n$1"+digits), MaxBulkSizeLength)
+	pe = assertProtocolError(t, err)
+	assert.Equal(t, "too big bulk count string", pe.Reason)
+	assert.Equal(t, 4+MaxInlineCommandLength, pe.Offset)
+}
+
+func TestParseNestedArray(t *testing.T) {
+	// Reply shaped like EXEC results: [OK, [1, [a, nil]], nil array]
+	ra, read, _ := parseArray([]byte("*3\r\n+OK\r\n*2\r\n:1\r\n*2\r\n$1\r\na\r\n$-1\r\n*-1\r\n:7\r\n"))
+	assert.Equal(t, ra.GetNumberOfItems(), 3)
+	assert.Equal(t, read, 38, "Bytes after the outer array must not be consumed")
+	inner, ok := ra.GetItemAtIndex(1).(Array)
+	assert.True(t, ok, "Nested array must be parsed as Array")
+	assert.Equal(t, inner.GetNumberOfItems(), 2)
+	innermost := inner.GetItemAtIndex(1).(Array)
+	assert.Equal(t, innermost.GetItemAtIndex(0).ToString(), "a")
+	assert.Equal(t, innermost.GetItemAtIndex(1).(BulkString).IsNull(), true)
+	assert.Equal(t, ra.GetItemAtIndex(2).(Array).IsNull(), true, "Nested null array must be parsed")
+
+	// Nesting up to the limit is allowed, one level deeper is not
+	deep := strings.Repeat("*1\r\n", MaxArrayNestingDepth) + ":1\r\n"
+	_, read, _ = parseArray([]byte(deep))
+	assert.Equal(t, read, len(deep))
+	_, _, err := parseArray([]byte("*1\r\n" + deep))
+	assertProtocolError(t, err, "parseArray fails if arrays are nested deeper than the limit")
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	inner, _ := NewArray(2)
+	bs, _ := NewBulkString("v")
+	inner.SetItemAtIndex(0, bs)
+	inner.SetItemAtIndex(1, *NewNullArray())
+	outer, _ := NewArray(4)
+	outer.SetItemAtIndex(0, NewString("OK"))
+	outer.SetItemAtIndex(1, NewInteger(-3))
+	outer.SetItemAtIndex(2, *inner)
+	outer.SetItemAtIndex(3, NewDefaultRedisError("boom"))
+	encoded := outer.Encode()
+	value, read, err := parseReply(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, read, len(encoded))
+	assert.Equal(t, encoded, value.Encode(), "Encoded output must parse back to the same value")
+}
+
+func TestParseRedisClientRequestPipeline(t *testing.T) {
+	// Values containing * must not be mistaken for the start of the next command
+	ras, read, err := ParseRedisClientRequest([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$3\r\na*b\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(ras), "Both commands in the pipeline must be parsed")
+	assert.Equal(t, 49, read)
+	assert.Equal(t, "a*b", ras[0].GetItemAtIndex(2).ToString())
+	assert.Equal(t, "GET", ras[1].GetItemAtIndex(0).ToString())
+
+	// A JSON blob with several * in it
+	ras, _, err = ParseRedisClientRequest([]byte("*3\r\n$4\r\nMSET\r\n$1\r\nj\r\n$14\r\n{\"a\":\"*1\\r\\n\"}\r\n*1\r\n$4\r\nPING\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(ras))
+	assert.Equal(t, "{\"a\":\"*1\\r\\n\"}", ras[0].GetItemAtIndex(2).ToString())
+}
+
+func TestParseRedisClientRequestProtocolError(t *testing.T) {
+	ras, read, err := ParseRedisClientRequest([]byte("*1\r\n$4\r\nPING\r\n*1\r\n$x\r\n"))
+	assert.Equal(t, 1, len(ras), "Commands before the error must be returned")
+	assert.Equal(t, 14, read)
+	pe := assertProtocolError(t, err)
+	assert.Equal(t, 19, pe.Offset, "Offset must count from the start of the input")
+	assert.Equal(t, "invalid bulk length", pe.Reason)
+	assert.Equal(t, "-ERR Protocol error: invalid bulk length\r\n", string(pe.RedisError().Encode()))
+}
+
+func TestParseRedisClientRequestPartial(t *testing.T) {
+	// Second command is cut off in the middle of a bulk string
+	ras, read, err := ParseRedisClientRequest([]byte("*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGET\r\n$1\r\n"))
+	assert.Nil(t, err, "Partial commands are not an error")
+	assert.Equal(t, 1, len(ras), "Only complete commands must be returned")
+	assert.Equal(t, 14, read, "Bytes of the partial command must not be consumed")
+}
+
+// Build a pipeline of n SET commands, as sent by a load generator
+func buildPipeline(n int) []byte {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key:%06d", i)
+		fmt.Fprintf(&b, "*3\r\n$3\r\nSET\r\n$%d\r\n%s\r\n$5\r\nvalue\r\n", len(key), key)
+	}
+	return []byte(b.String())
+}
+
+func TestParseRedisClientRequestLargePipeline(t *testing.T) {
+	pipeline := buildPipeline(20000)
+	input := append([]byte{}, pipeline...)
+	ras, read, err := ParseRedisClientRequest(input)
+	assert.Nil(t, err)
+	assert.Equal(t, 20000, len(ras))
+	assert.Equal(t, len(pipeline), read)
+	assert.Equal(t, "key:019999", ras[19999].GetItemAtIndex(1).ToString())
+	assert.Equal(t, pipeline, input, "Parsing must not modify the input")
+}
+
+// BenchmarkParseRedisClientRequest parses a pipeline of 10000 commands per
+// op. Divide allocs/op by 10000 for the allocations per command
+func BenchmarkParseRedisClientRequest(b *testing.B) {
+	pipeline := buildPipeline(10000)
+	b.SetBytes(int64(len(pipeline)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParseRedisClientRequest(pipeline)
+	}
 }
@@ -0,0 +1,180 @@
+package resp
+
+import (
+	"io"
+)
+
+// Size of each read from the underlying reader
+const readChunkSize = 4096
+
+// Reader decodes commands from a stream of bytes, such as a network connection.
+// A single Read from the stream may hold part of a command, or several commands
+// at once, so the Reader buffers incoming bytes until a full command is available
+// and keeps any leftover bytes for the next call.
+type Reader struct {
+	rd io.Reader
+	// Bytes read from rd, of which buf[start:] are not yet consumed
+	buf   []byte
+	start int
+	// Number of bytes consumed since the start of the stream
+	consumed int
+	// Longest bulk string of a command
+	maxBulkLength int64
+}
+
+// NewReader creates a new Reader that reads from rd
+func NewReader(rd io.Reader) *Reader {
+	return &Reader{
+		rd:            rd,
+		buf:           make([]byte, 0, readChunkSize),
+		maxBulkLength: MaxBulkSizeLength,
+	}
+}
+
+// SetMaxBulkLength changes the longest bulk string accepted in commands, like
+// the proto-max-bulk-len setting of Redis. It defaults to MaxBulkSizeLength.
+// Values read with ReadValue have no limit.
+func (r *Reader) SetMaxBulkLength(n int64) {
+	r.maxBulkLength = n
+}
+
+// Buffered returns the number of bytes that have been read from the stream
+// but not yet consumed by a command
+func (r *Reader) Buffered() int {
+	return len(r.buf) - r.start
+}
+
+// ReadCommand returns the next command in the stream, sent either as a RESP
+// array or inline. It blocks until a full command has arrived. It returns io.EOF if the stream ends between commands and
+// io.ErrUnexpectedEOF if it ends in the middle of one. If the stream does not
+// follow the protocol, a *ProtocolError is returned whose offset counts from
+// the start of the stream. The buffered bytes are discarded then, since there
+// is no way of telling where the next command starts.
+//
+// The bulk strings of the command are not copied out of the Reader's buffer,
+// so they are only valid until the next call to ReadCommand. Callers that keep
+// an argument around, like a value to store, must copy it.
+func (r *Reader) ReadCommand() (*Array, error) {
+	for {
+		value, err := r.next(func(b []byte) (IDataType, int, error) {
+			command, read, err := parseCommand(b, r.maxBulkLength)
+			if err != nil {
+				return nil, 0, err
+			}
+			return command, read, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		command := value.(*Array)
+		// Redis silently skips empty commands, like blank inline lines
+		if command.GetNumberOfItems() > 0 {
+			return command, nil
+		}
+	}
+}
+
+// ReadValue returns the next value of any type in the stream, such as a reply
+// sent by a server. It behaves like ReadCommand otherwise, except that the
+// value is copied out of the buffer, since replies are usually kept around.
+func (r *Reader) ReadValue() (IDataType, error) {
+	value, err := r.next(parseReply)
+	if err != nil {
+		return nil, err
+	}
+	return copyValue(value), nil
+}
+
+// copyValue returns a deep copy of the byte slices held by dt, so that it no
+// longer shares memory with the buffer it was parsed from
+func copyValue(dt IDataType) IDataType {
+	switch v := dt.(type) {
+	case BulkString:
+		if v.isNullValue {
+			return v
+		}
+		return BulkString{value: append([]byte{}, v.value...)}
+	case VerbatimString:
+		return VerbatimString{format: v.format, value: append([]byte{}, v.value...)}
+	case Array:
+		if v.isNullValue {
+			return v
+		}
+		return Array{items: copyItems(v.items)}
+	case Map:
+		return Map{keys: copyItems(v.keys), values: copyItems(v.values)}
+	case Set:
+		return Set{items: copyItems(v.items)}
+	case Push:
+		return Push{items: copyItems(v.items)}
+	case Attribute:
+		attributes := Map{keys: copyItems(v.attributes.keys), values: copyItems(v.attributes.values)}
+		return Attribute{attributes: &attributes, value: copyValue(v.value)}
+	}
+	return dt
+}
+
+// Copy each item of an aggregate
+func copyItems(items []IDataType) []IDataType {
+	copied := make([]IDataType, len(items))
+	for i, item := range items {
+		copied[i] = copyValue(item)
+	}
+	return copied
+}
+
+// next buffers the stream until parse finds a complete value in it
+func (r *Reader) next(parse func([]byte) (IDataType, int, error)) (IDataType, error) {
+	for {
+		if r.Buffered() > 0 {
+			value, read, err := parse(r.buf[r.start:])
+			if err == nil {
+				r.start += read
+				r.consumed += read
+				return value, nil
+			}
+			if err != errIncompleteFrame {
+				r.reset()
+				return nil, offsetBy(err, r.consumed)
+			}
+		}
+		if err := r.fill(); err != nil {
+			if err == io.EOF && r.Buffered() > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+}
+
+// fill reads the next chunk from the stream into the buffer. Consumed bytes
+// are dropped first, so that the buffer does not grow with the stream.
+func (r *Reader) fill() error {
+	if r.start > 0 {
+		n := copy(r.buf, r.buf[r.start:])
+		r.buf = r.buf[:n]
+		r.start = 0
+	}
+	if cap(r.buf)-len(r.buf) < readChunkSize {
+		grown := make([]byte, len(r.buf), 2*cap(r.buf)+readChunkSize)
+		copy(grown, r.buf)
+		r.buf = grown
+	}
+	n, err := r.rd.Read(r.buf[len(r.buf):cap(r.buf)])
+	r.buf = r.buf[:len(r.buf)+n]
+	if n > 0 {
+		// Parse what we have before reporting any error
+		return nil
+	}
+	if err == nil {
+		// A reader may return no bytes without error, try again
+		return nil
+	}
+	return err
+}
+
+// reset discards all buffered bytes
+func (r *Reader) reset() {
+	r.buf = r.buf[:0]
+	r.start = 0
+}
@@ -0,0 +1,185 @@
+package resp
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkedReader returns the given chunks one Read at a time, like TCP segments
+type chunkedReader struct {
+	chunks []string
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, c.chunks[0])
+	c.chunks[0] = c.chunks[0][n:]
+	if len(c.chunks[0]) == 0 {
+		c.chunks = c.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestReaderSplitCommand(t *testing.T) {
+	r := NewReader(&chunkedReader{chunks: []string{"*2\r\n$3\r\nGE", "T\r\n$1", "\r\nk\r\n"}})
+	ra, err := r.ReadCommand()
+	assert.Nil(t, err, "A command split across reads must parse once complete")
+	assert.Equal(t, 2, ra.GetNumberOfItems())
+	assert.Equal(t, "GET", ra.GetItemAtIndex(0).ToString())
+	assert.Equal(t, "k", ra.GetItemAtIndex(1).ToString())
+	_, err = r.ReadCommand()
+	assert.Equal(t, io.EOF, err, "Stream ending between commands must return io.EOF")
+}
+
+func TestReaderByteByByte(t *testing.T) {
+	r := NewReader(iotest.OneByteReader(strings.NewReader("*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\n")))
+	ra, err := r.ReadCommand()
+	assert.Nil(t, err)
+	assert.Equal(t, "PING", ra.GetItemAtIndex(0).ToString())
+	ra, err = r.ReadCommand()
+	assert.Nil(t, err)
+	assert.Equal(t, "GET", ra.GetItemAtIndex(0).ToString())
+}
+
+func TestReaderCoalescedCommands(t *testing.T) {
+	r := NewReader(&chunkedReader{chunks: []string{"*1\r\n$4\r\nPING\r\n*1\r\n$4\r\nPI", "NG\r\n"}})
+	ra, err := r.ReadCommand()
+	assert.Nil(t, err)
+	assert.Equal(t, "PING", ra.GetItemAtIndex(0).ToString())
+	assert.Equal(t, 10, r.Buffered(), "Leftover bytes must stay in the buffer")
+	ra, err = r.ReadCommand()
+	assert.Nil(t, err)
+	assert.Equal(t, "PING", ra.GetItemAtIndex(0).ToString())
+	assert.Equal(t, 0, r.Buffered())
+}
+
+func TestReaderLargeCommand(t *testing.T) {
+	value := strings.Repeat("v", 3*readChunkSize)
+	r := NewReader(strings.NewReader("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$12288\r\n" + value + "\r\n"))
+	ra, err := r.ReadCommand()
+	assert.Nil(t, err, "Commands larger than a single read must be buffered")
+	assert.Equal(t, value, ra.GetItemAtIndex(2).ToString())
+}
+
+func TestReaderUnexpectedEOF(t *testing.T) {
+	r := NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n"))
+	_, err := r.ReadCommand()
+	assert.Equal(t, io.ErrUnexpectedEOF, err, "Stream ending inside a command must return io.ErrUnexpectedEOF")
+}
+
+func TestReaderProtocolError(t *testing.T) {
+	r := NewReader(strings.NewReader("*1\r\n$4\r\nPING\r\n*1\r\n$ab\r\n"))
+	_, err := r.ReadCommand()
+	assert.Nil(t, err)
+	_, err = r.ReadCommand()
+	pe, ok := err.(*ProtocolError)
+	assert.True(t, ok, "Protocol errors must be returned as *ProtocolError")
+	assert.Equal(t, 19, pe.Offset, "Offset must count from the start of the stream")
+	assert.Equal(t, 0, r.Buffered(), "Buffer must be discarded after a protocol error")
+}
+
+func TestReaderMaxBulkLength(t *testing.T) {
+	large := strings.Repeat("x", MaxBulkSizeLength+1)
+	command := "*1\r\n$" + strconv.Itoa(len(large)) + "\r\n" + large + "\r\n"
+	_, err := NewReader(strings.NewReader(command)).ReadCommand()
+	pe, ok := err.(*ProtocolError)
+	assert.True(t, ok, "Commands must be limited to MaxBulkSizeLength by default")
+	assert.Equal(t, "invalid bulk length", pe.Reason)
+
+	r := NewReader(strings.NewReader(command))
+	r.SetMaxBulkLength(2 * MaxBulkSizeLength)
+	ra, err := r.ReadCommand()
+	assert.Nil(t, err, "Raising the limit must allow longer bulk strings")
+	assert.Equal(t, len(large), len(ra.GetItemAtIndex(0).ToString()))
+
+	r = NewReader(strings.NewReader("$" + strconv.Itoa(len(large)) + "\r\n" + large + "\r\n"))
+	r.SetMaxBulkLength(3)
+	_, err = r.ReadValue()
+	assert.Nil(t, err, "Values must not be limited")
+}
+
+func TestReaderReadValue(t *testing.T) {
+	r := NewReader(&chunkedReader{chunks: []string{"+OK\r\n*2\r\n*1\r\n:1", "\r\n$-1\r\n-ERR x\r\n"}})
+	v, err := r.ReadValue()
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", v.ToString())
+	v, err = r.ReadValue()
+	assert.Nil(t, err)
+	ra, ok := v.(Array)
+	assert.True(t, ok, "Arrays must be returned by value like nested items")
+	assert.Equal(t, 2, ra.GetNumberOfItems())
+	v, err = r.ReadValue()
+	assert.Nil(t, err)
+	_, ok = v.(RedisError)
+	assert.True(t, ok, "Error replies are values, not read errors")
+}
+
+func TestReaderInlineCommands(t *testing.T) {
+	r := NewReader(&chunkedReader{chunks: []string{"\r\nSET foo \"ba", "r\"\r\nGET foo\n"}})
+	ra, err := r.ReadCommand()
+	assert.Nil(t, err, "Blank lines must be skipped and split lines buffered")
+	assert.Equal(t, "SET", ra.GetItemAtIndex(0).ToString())
+	assert.Equal(t, "bar", ra.GetItemAtIndex(2).ToString())
+	ra, err = r.ReadCommand()
+	assert.Nil(t, err)
+	assert.Equal(t, "GET", ra.GetItemAtIndex(0).ToString())
+}
+
+func TestReaderReadValueCopies(t *testing.T) {
+	r := NewReader(&chunkedReader{chunks: []string{"*2\r\n$3\r\nfoo\r\n%1\r\n$1\r\nk\r\n$1\r\nv\r\n", "$3\r\nbar\r\n"}})
+	v, err := r.ReadValue()
+	assert.Nil(t, err)
+	// Reading on compacts the buffer over the first reply
+	_, err = r.ReadValue()
+	assert.Nil(t, err)
+	assert.Equal(t, "[foo,{k:v}]", v.ToString(), "Replies must not share the Reader's buffer")
+}
+
+// repeatReader returns data over and over, like a client that never stops
+// sending the same pipeline
+type repeatReader struct {
+	data   []byte
+	offset int
+}
+
+func (rr *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], rr.data[rr.offset:])
+		n += c
+		rr.offset = (rr.offset + c) % len(rr.data)
+	}
+	return n, nil
+}
+
+func TestReaderReadCommandAllocations(t *testing.T) {
+	r := NewReader(&repeatReader{data: buildPipeline(1000)})
+	allocs := testing.AllocsPerRun(1000, func() {
+		r.ReadCommand()
+	})
+	// The array, its items and one boxed bulk string per argument. Payloads
+	// are sliced from the buffer rather than copied
+	assert.True(t, allocs <= 5, "Expected at most 5 allocations per command, got %v", allocs)
+}
+
+// BenchmarkReaderReadCommand reads one command per op from an endless
+// pipeline, so allocs/op are the allocations per command
+func BenchmarkReaderReadCommand(b *testing.B) {
+	pipeline := buildPipeline(10000)
+	r := NewReader(&repeatReader{data: pipeline})
+	b.SetBytes(int64(len(pipeline) / 10000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.ReadCommand(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -29,8 +29,18 @@ var (
 type IDataType interface {
 	isDataType() bool
 	ToString() string
+	// Encode returns the RESP wire representation of the value
+	Encode() []byte
 }
 
+// CRLF terminates every RESP line on the wire
+const crlf = "\r\n"
+
+// Replaces the line breaks of simple strings and errors, which would end them
+// early on the wire, with spaces like Redis does. Errors often echo what the
+// client sent, which must not be able to forge replies
+var lineBreaks = strings.NewReplacer("\r", " ", "\n", " ")
+
 // Each primtive datatype returns a value of the underlying struct, rather than a pointer.
 // Only array type returns a pointer reference
 
@@ -53,6 +63,12 @@ func (s String) ToString() string {
 	return s.value
 }
 
+// Encode returns the simple string as +value\r\n, with line breaks in value
+// replaced by spaces
+func (s String) Encode() []byte {
+	return []byte(string(stringStartByte) + lineBreaks.Replace(s.value) + crlf)
+}
+
 // NewString creates a new instance of String
 func NewString(s string) String {
 	return String{value: s}
@@ -81,6 +97,22 @@ func (em RedisError) ToString() string {
 	return em.ecode + " " + em.message
 }
 
+// Error implements the error interface, so that a RedisError can be returned
+// wherever Go expects an error
+func (em RedisError) Error() string {
+	return em.ToString()
+}
+
+// Encode returns the error as -ECODE message\r\n, with line breaks replaced
+// by spaces. The message is omitted when it is empty, so that no trailing
+// whitespace is sent
+func (em RedisError) Encode() []byte {
+	if em.message == "" {
+		return []byte(string(errorStartByte) + lineBreaks.Replace(em.ecode) + crlf)
+	}
+	return []byte(string(errorStartByte) + lineBreaks.Replace(em.ToString()) + crlf)
+}
+
 // NewRedisError creates a new instance of RedisError
 func NewRedisError(ecode string, message string) RedisError {
 	return RedisError{ecode, message}
@@ -98,27 +130,32 @@ func NewDefaultRedisError(message string) RedisError {
 // Integer
 ///////////////////
 
-// Integer wraps integer value
+// Integer wraps a signed 64 bit integer, the range of Redis integers
 type Integer struct {
-	value int
+	value int64
 }
 
 // ToString returns string equivalent of integer
 func (i Integer) ToString() string {
-	return strconv.Itoa(i.value)
+	return strconv.FormatInt(i.value, 10)
 }
 
 func (Integer) isDataType() bool {
 	return true
 }
 
-// GetIntegerValue returns the underlying int value
-func (i Integer) GetIntegerValue() int {
+// Encode returns the integer as :value\r\n
+func (i Integer) Encode() []byte {
+	return []byte(string(integerStartByte) + strconv.FormatInt(i.value, 10) + crlf)
+}
+
+// GetIntegerValue returns the underlying int64 value
+func (i Integer) GetIntegerValue() int64 {
 	return i.value
 }
 
 // NewInteger creates a new instance of Integer
-func NewInteger(integer int) Integer {
+func NewInteger(integer int64) Integer {
 	return Integer{value: integer}
 }
 
@@ -129,16 +166,30 @@ func NewInteger(integer int) Integer {
 // BulkString represents binary-safe strings upto 512 MB in length
 type BulkString struct {
 	isNullValue bool
-	value       string
+	value       []byte
 }
 
 // This value is much lower than 512MB allowed in Redis. Our project
-// is just a PoC :).
+// is just a PoC :). It is the default limit on the bulk strings of commands,
+// which Reader.SetMaxBulkLength changes
 const (
 	MaxBulkSizeLength               = 1 * 1024 * 1024
 	MaxBulkSizeAsHumanReadableValue = "1MB"
 )
 
+// MaxStringLength is the largest bulk string that can be created, and the
+// highest limit on the bulk strings of commands, 512MB like the largest
+// strings of Redis
+const MaxStringLength = 512 * 1024 * 1024
+
+// Check a length against the largest bulk string
+func checkBulkLength(length int) error {
+	if length > MaxStringLength {
+		return errors.New("Cannot allocate a string of length " + strconv.Itoa(length) + " because it exceeds max allowed size of " + strconv.Itoa(MaxStringLength) + " bytes")
+	}
+	return nil
+}
+
 // Public method to check if string is a null value
 func (r BulkString) IsNull() bool {
 	return r.isNullValue
@@ -154,9 +205,26 @@ func (bs BulkString) ToString() string {
 	if bs.isNullValue {
 		return "(nil)"
 	}
+	return string(bs.value)
+}
+
+// Bytes returns the raw bytes of the bulk string, nil for a null bulk string.
+// Unlike ToString, this is safe for binary payloads
+func (bs BulkString) Bytes() []byte {
 	return bs.value
 }
 
+// Encode returns the bulk string as $length\r\nvalue\r\n. Null bulk strings
+// are encoded with a length of -1 and no payload
+func (bs BulkString) Encode() []byte {
+	if bs.isNullValue {
+		return []byte(string(bulkStringStartByte) + "-1" + crlf)
+	}
+	encoded := []byte(string(bulkStringStartByte) + strconv.Itoa(len(bs.value)) + crlf)
+	encoded = append(encoded, bs.value...)
+	return append(encoded, crlf...)
+}
+
 // Bulk string constructors
 func NewNullBulkString() BulkString {
 	return BulkString{
@@ -167,12 +235,21 @@ func NewNullBulkString() BulkString {
 // NewBulkString will create a new BulkString. It returns an error if
 // the bulk string was created without errors
 func NewBulkString(str string) (BulkString, error) {
-	strLen := len(str)
-	if strLen > MaxBulkSizeLength {
-		return BulkString{}, errors.New("Cannot allocate a string of length " + string(strLen) + " because it exceeds max allowed size of " + MaxBulkSizeAsHumanReadableValue)
+	return NewBulkStringFromBytes([]byte(str))
+}
+
+// NewBulkStringFromBytes creates a new BulkString holding b as is, which
+// may be arbitrary binary data. The BulkString takes ownership of b.
+func NewBulkStringFromBytes(b []byte) (BulkString, error) {
+	if err := checkBulkLength(len(b)); err != nil {
+		return BulkString{}, err
+	}
+	if b == nil {
+		// Keep empty strings distinguishable from null ones in Bytes()
+		b = []byte{}
 	}
 	return BulkString{
-		value:       str,
+		value:       b,
 		isNullValue: false,
 	}, nil
 }
@@ -185,7 +262,8 @@ func NewBulkString(str string) (BulkString, error) {
 // In the serialization protocol, it is used for sending commands from
 // a client to Redis server
 type Array struct {
-	items []IDataType
+	isNullValue bool
+	items       []IDataType
 }
 
 // Tag Array as part of IRESPDataType
@@ -193,8 +271,16 @@ func (Array) isDataType() bool {
 	return true
 }
 
-// Return the array representation
+// IsNull checks if the array is a null array
+func (ra Array) IsNull() bool {
+	return ra.isNullValue
+}
+
+// Return the array representation, nil if appropriate
 func (ra Array) ToString() string {
+	if ra.isNullValue {
+		return "(nil)"
+	}
 	itemRepr := make([]string, len(ra.items))
 	for i, item := range ra.items {
 		itemRepr[i] = item.ToString()
@@ -202,6 +288,22 @@ func (ra Array) ToString() string {
 	return "[" + strings.Join(itemRepr, ",") + "]"
 }
 
+// Encode returns the array as *count\r\n followed by each encoded item.
+// Unset items are encoded as null bulk strings, null arrays with a count of -1
+func (ra Array) Encode() []byte {
+	if ra.isNullValue {
+		return []byte(string(arrayStartByte) + "-1" + crlf)
+	}
+	encoded := []byte(string(arrayStartByte) + strconv.Itoa(len(ra.items)) + crlf)
+	for _, item := range ra.items {
+		if item == nil {
+			item = EmptyBulkString
+		}
+		encoded = append(encoded, item.Encode()...)
+	}
+	return encoded
+}
+
 // GetNumberOfItems returns number of inner items
 func (ra *Array) GetNumberOfItems() int {
 	return len(ra.items)
@@ -217,6 +319,19 @@ func (ra *Array) SetItemAtIndex(index int, dt IDataType) {
 	ra.items[index] = dt
 }
 
+// Append adds items to the end of the array. Appending to a null array turns
+// it into a regular one
+func (ra *Array) Append(items ...IDataType) {
+	ra.isNullValue = false
+	ra.items = append(ra.items, items...)
+}
+
+// NewNullArray creates a null Array, which Redis uses to denote the absence
+// of a result, like the null bulk string
+func NewNullArray() *Array {
+	return &Array{isNullValue: true}
+}
+
 // NewArray creates a new instance of Array
 func NewArray(numberOfItems int) (*Array, error) {
 	if numberOfItems < 0 {
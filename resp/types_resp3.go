@@ -0,0 +1,449 @@
+package resp
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Data types introduced by RESP3. A connection only receives them after it
+// switched protocols with HELLO 3; for RESP2 connections the Encoder converts
+// them into their closest RESP2 equivalent.
+
+// Placeholder constants for RESP3 types, in the spirit of EmptyString et al.
+var (
+	EmptyNull    = Null{}
+	EmptyBoolean = Boolean{}
+	EmptyDouble  = Double{}
+)
+
+///////////////////
+// Map
+///////////////////
+
+// Map holds an ordered sequence of key-value pairs. Redis replies with maps
+// for commands like HGETALL or CONFIG GET
+type Map struct {
+	keys   []IDataType
+	values []IDataType
+}
+
+// Tag Map as part of IDataType
+func (Map) isDataType() bool {
+	return true
+}
+
+// ToString returns the map representation
+func (m Map) ToString() string {
+	pairRepr := make([]string, len(m.keys))
+	for i := range m.keys {
+		pairRepr[i] = m.keys[i].ToString() + ":" + m.values[i].ToString()
+	}
+	return "{" + strings.Join(pairRepr, ",") + "}"
+}
+
+// Encode returns the map as %count\r\n followed by each encoded key and value
+func (m Map) Encode() []byte {
+	encoded := []byte(string(mapStartByte) + strconv.Itoa(len(m.keys)) + crlf)
+	return appendPairs(encoded, m.keys, m.values)
+}
+
+// GetNumberOfPairs returns the number of key-value pairs
+func (m *Map) GetNumberOfPairs() int {
+	return len(m.keys)
+}
+
+// GetPairAtIndex returns the key and value of the pair at given index
+func (m *Map) GetPairAtIndex(index int) (IDataType, IDataType) {
+	return m.keys[index], m.values[index]
+}
+
+// Add appends a key-value pair to the map. Keys are not checked for
+// uniqueness, the caller is expected to add each key once
+func (m *Map) Add(key IDataType, value IDataType) {
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+}
+
+// NewMap creates a new empty Map
+func NewMap() *Map {
+	return &Map{
+		keys:   make([]IDataType, 0),
+		values: make([]IDataType, 0),
+	}
+}
+
+// Encode keys and values alternately, unset values as null bulk strings
+func appendPairs(encoded []byte, keys []IDataType, values []IDataType) []byte {
+	for i := range keys {
+		encoded = append(encoded, keys[i].Encode()...)
+		value := values[i]
+		if value == nil {
+			value = EmptyBulkString
+		}
+		encoded = append(encoded, value.Encode()...)
+	}
+	return encoded
+}
+
+///////////////////
+// Set
+///////////////////
+
+// Set holds an unordered collection of distinct items, like the reply of
+// SMEMBERS
+type Set struct {
+	items []IDataType
+}
+
+// Tag Set as part of IDataType
+func (Set) isDataType() bool {
+	return true
+}
+
+// ToString returns the set representation
+func (st Set) ToString() string {
+	itemRepr := make([]string, len(st.items))
+	for i, item := range st.items {
+		itemRepr[i] = item.ToString()
+	}
+	return "{" + strings.Join(itemRepr, ",") + "}"
+}
+
+// Encode returns the set as ~count\r\n followed by each encoded item
+func (st Set) Encode() []byte {
+	encoded := []byte(string(setStartByte) + strconv.Itoa(len(st.items)) + crlf)
+	for _, item := range st.items {
+		encoded = append(encoded, item.Encode()...)
+	}
+	return encoded
+}
+
+// GetNumberOfItems returns number of items in the set
+func (st *Set) GetNumberOfItems() int {
+	return len(st.items)
+}
+
+// GetItemAtIndex returns the item at given index
+func (st *Set) GetItemAtIndex(index int) IDataType {
+	return st.items[index]
+}
+
+// Add appends an item to the set. Items are not checked for uniqueness,
+// the caller is expected to add each item once
+func (st *Set) Add(item IDataType) {
+	st.items = append(st.items, item)
+}
+
+// NewSet creates a new empty Set
+func NewSet() *Set {
+	return &Set{items: make([]IDataType, 0)}
+}
+
+///////////////////
+// Double
+///////////////////
+
+// Double wraps a floating point value, like the reply of ZSCORE
+type Double struct {
+	value float64
+}
+
+// Tag Double as part of IDataType
+func (Double) isDataType() bool {
+	return true
+}
+
+// ToString returns the shortest representation of the double. Infinities
+// and NaN are spelled the way Redis spells them
+func (d Double) ToString() string {
+	switch {
+	case math.IsInf(d.value, 1):
+		return "inf"
+	case math.IsInf(d.value, -1):
+		return "-inf"
+	case math.IsNaN(d.value):
+		return "nan"
+	}
+	return strconv.FormatFloat(d.value, 'g', -1, 64)
+}
+
+// Encode returns the double as ,value\r\n
+func (d Double) Encode() []byte {
+	return []byte(string(doubleStartByte) + d.ToString() + crlf)
+}
+
+// GetDoubleValue returns the underlying float64 value
+func (d Double) GetDoubleValue() float64 {
+	return d.value
+}
+
+// NewDouble creates a new instance of Double
+func NewDouble(value float64) Double {
+	return Double{value: value}
+}
+
+///////////////////
+// Boolean
+///////////////////
+
+// Boolean wraps a true or false value
+type Boolean struct {
+	value bool
+}
+
+// Tag Boolean as part of IDataType
+func (Boolean) isDataType() bool {
+	return true
+}
+
+// ToString returns (true) or (false), like redis-cli does
+func (b Boolean) ToString() string {
+	if b.value {
+		return "(true)"
+	}
+	return "(false)"
+}
+
+// Encode returns the boolean as #t\r\n or #f\r\n
+func (b Boolean) Encode() []byte {
+	if b.value {
+		return []byte(string(booleanStartByte) + "t" + crlf)
+	}
+	return []byte(string(booleanStartByte) + "f" + crlf)
+}
+
+// GetBooleanValue returns the underlying bool value
+func (b Boolean) GetBooleanValue() bool {
+	return b.value
+}
+
+// NewBoolean creates a new instance of Boolean
+func NewBoolean(value bool) Boolean {
+	return Boolean{value: value}
+}
+
+///////////////////
+// Null
+///////////////////
+
+// Null is the single null type of RESP3, which replaces the null bulk string
+// and null array of RESP2
+type Null struct{}
+
+// Tag Null as part of IDataType
+func (Null) isDataType() bool {
+	return true
+}
+
+// ToString returns (nil), like the RESP2 null types
+func (Null) ToString() string {
+	return "(nil)"
+}
+
+// Encode returns the null as _\r\n
+func (Null) Encode() []byte {
+	return []byte(string(nullStartByte) + crlf)
+}
+
+// NewNull creates a new instance of Null
+func NewNull() Null {
+	return Null{}
+}
+
+///////////////////
+// BigNumber
+///////////////////
+
+// BigNumber holds an integer outside of the signed 64 bit range
+type BigNumber struct {
+	value *big.Int
+}
+
+// Tag BigNumber as part of IDataType
+func (BigNumber) isDataType() bool {
+	return true
+}
+
+// ToString returns the decimal representation of the number
+func (bn BigNumber) ToString() string {
+	if bn.value == nil {
+		return "0"
+	}
+	return bn.value.String()
+}
+
+// Encode returns the number as (digits\r\n
+func (bn BigNumber) Encode() []byte {
+	return []byte(string(bigNumberStartByte) + bn.ToString() + crlf)
+}
+
+// GetBigIntValue returns a copy of the underlying big.Int
+func (bn BigNumber) GetBigIntValue() *big.Int {
+	if bn.value == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(bn.value)
+}
+
+// NewBigNumber creates a new instance of BigNumber holding a copy of value
+func NewBigNumber(value *big.Int) BigNumber {
+	return BigNumber{value: new(big.Int).Set(value)}
+}
+
+///////////////////
+// VerbatimString
+///////////////////
+
+// VerbatimString is a binary-safe string tagged with a three letter format,
+// txt for plain text or mkd for markdown. Clients may show it as is
+type VerbatimString struct {
+	format string
+	value  []byte
+}
+
+// Tag VerbatimString as part of IDataType
+func (VerbatimString) isDataType() bool {
+	return true
+}
+
+// ToString returns the text without its format
+func (vs VerbatimString) ToString() string {
+	return string(vs.value)
+}
+
+// Encode returns the string as =length\r\nfmt:value\r\n
+func (vs VerbatimString) Encode() []byte {
+	encoded := []byte(string(verbatimStringStartByte) + strconv.Itoa(len(vs.format)+1+len(vs.value)) + crlf + vs.format + ":")
+	encoded = append(encoded, vs.value...)
+	return append(encoded, crlf...)
+}
+
+// GetFormat returns the three letter format of the string
+func (vs VerbatimString) GetFormat() string {
+	return vs.format
+}
+
+// Bytes returns the raw bytes of the text, without its format
+func (vs VerbatimString) Bytes() []byte {
+	return vs.value
+}
+
+// NewVerbatimString creates a new VerbatimString. The format must be exactly
+// three characters long, like txt or mkd
+func NewVerbatimString(format string, value []byte) (VerbatimString, error) {
+	if len(format) != 3 {
+		return VerbatimString{}, errors.New("Verbatim string format must be 3 characters long, got '" + format + "'")
+	}
+	if err := checkBulkLength(len(value) + 4); err != nil {
+		return VerbatimString{}, err
+	}
+	return VerbatimString{format: format, value: value}, nil
+}
+
+///////////////////
+// Attribute
+///////////////////
+
+// Attribute carries auxiliary key-value pairs, such as key popularity, along
+// with the reply they describe. On the wire the pairs come first, followed by
+// the reply itself
+type Attribute struct {
+	attributes *Map
+	value      IDataType
+}
+
+// Tag Attribute as part of IDataType
+func (Attribute) isDataType() bool {
+	return true
+}
+
+// ToString returns the representation of the described reply. Attributes
+// are meant to be ignored by clients that do not know them
+func (a Attribute) ToString() string {
+	if a.value == nil {
+		return "(nil)"
+	}
+	return a.value.ToString()
+}
+
+// Encode returns the attribute as |count\r\n followed by each encoded key and
+// value, and then the encoded reply
+func (a Attribute) Encode() []byte {
+	encoded := []byte(string(attributeStartByte) + strconv.Itoa(len(a.attributes.keys)) + crlf)
+	encoded = appendPairs(encoded, a.attributes.keys, a.attributes.values)
+	value := a.value
+	if value == nil {
+		value = EmptyBulkString
+	}
+	return append(encoded, value.Encode()...)
+}
+
+// GetAttributes returns the auxiliary key-value pairs
+func (a Attribute) GetAttributes() *Map {
+	return a.attributes
+}
+
+// GetValue returns the reply that the attributes describe
+func (a Attribute) GetValue() IDataType {
+	return a.value
+}
+
+// NewAttribute creates a new Attribute attaching attributes to value
+func NewAttribute(attributes *Map, value IDataType) Attribute {
+	if attributes == nil {
+		attributes = NewMap()
+	}
+	return Attribute{attributes: attributes, value: value}
+}
+
+///////////////////
+// Push
+///////////////////
+
+// Push holds out of band data sent by the server without a request, such as
+// pub/sub messages or client tracking invalidations
+type Push struct {
+	items []IDataType
+}
+
+// Tag Push as part of IDataType
+func (Push) isDataType() bool {
+	return true
+}
+
+// ToString returns the push representation
+func (p Push) ToString() string {
+	itemRepr := make([]string, len(p.items))
+	for i, item := range p.items {
+		itemRepr[i] = item.ToString()
+	}
+	return ">[" + strings.Join(itemRepr, ",") + "]"
+}
+
+// Encode returns the push as >count\r\n followed by each encoded item
+func (p Push) Encode() []byte {
+	encoded := []byte(string(pushStartByte) + strconv.Itoa(len(p.items)) + crlf)
+	for _, item := range p.items {
+		encoded = append(encoded, item.Encode()...)
+	}
+	return encoded
+}
+
+// GetNumberOfItems returns number of items in the push
+func (p *Push) GetNumberOfItems() int {
+	return len(p.items)
+}
+
+// GetItemAtIndex returns the item at given index
+func (p *Push) GetItemAtIndex(index int) IDataType {
+	return p.items[index]
+}
+
+// NewPush creates a new Push holding the given items. The first item is
+// usually the kind of the push, like message or invalidate
+func NewPush(items ...IDataType) *Push {
+	return &Push{items: items}
+}
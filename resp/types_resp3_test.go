@@ -0,0 +1,77 @@
+package resp
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	m := NewMap()
+	m.Add(NewString("a"), NewInteger(1))
+	m.Add(NewString("b"), nil)
+	assert.Equal(t, 2, m.GetNumberOfPairs())
+	k, v := m.GetPairAtIndex(0)
+	assert.Equal(t, "a", k.ToString())
+	assert.Equal(t, "1", v.ToString())
+	assert.Equal(t, "%2\r\n+a\r\n:1\r\n+b\r\n$-1\r\n", string(m.Encode()), "Map must encode keys and values alternately")
+}
+
+func TestSet(t *testing.T) {
+	st := NewSet()
+	st.Add(NewString("x"))
+	assert.Equal(t, 1, st.GetNumberOfItems())
+	assert.Equal(t, "~1\r\n+x\r\n", string(st.Encode()))
+}
+
+func TestDouble(t *testing.T) {
+	assert.Equal(t, ",1.5\r\n", string(NewDouble(1.5).Encode()))
+	assert.Equal(t, ",10\r\n", string(NewDouble(10).Encode()), "Whole numbers must not carry a fraction")
+	assert.Equal(t, ",inf\r\n", string(NewDouble(math.Inf(1)).Encode()))
+	assert.Equal(t, ",-inf\r\n", string(NewDouble(math.Inf(-1)).Encode()))
+	assert.Equal(t, ",nan\r\n", string(NewDouble(math.NaN()).Encode()))
+}
+
+func TestBoolean(t *testing.T) {
+	assert.Equal(t, "#t\r\n", string(NewBoolean(true).Encode()))
+	assert.Equal(t, "#f\r\n", string(NewBoolean(false).Encode()))
+	assert.Equal(t, true, NewBoolean(true).GetBooleanValue())
+}
+
+func TestNull(t *testing.T) {
+	assert.Equal(t, "_\r\n", string(NewNull().Encode()))
+	assert.Equal(t, "(nil)", NewNull().ToString())
+}
+
+func TestBigNumber(t *testing.T) {
+	n, _ := new(big.Int).SetString("3492890328409238509324850943850943825024385", 10)
+	bn := NewBigNumber(n)
+	assert.Equal(t, "(3492890328409238509324850943850943825024385\r\n", string(bn.Encode()))
+	n.SetInt64(0)
+	assert.Equal(t, "3492890328409238509324850943850943825024385", bn.ToString(), "BigNumber must not share memory with its argument")
+}
+
+func TestVerbatimString(t *testing.T) {
+	vs, err := NewVerbatimString("txt", []byte("Some string"))
+	assert.Nil(t, err)
+	assert.Equal(t, "=15\r\ntxt:Some string\r\n", string(vs.Encode()))
+	assert.Equal(t, "Some string", vs.ToString())
+	_, err = NewVerbatimString("text", []byte("x"))
+	assert.NotNil(t, err, "Format must be exactly 3 characters")
+}
+
+func TestAttribute(t *testing.T) {
+	attrs := NewMap()
+	attrs.Add(NewString("ttl"), NewInteger(3600))
+	a := NewAttribute(attrs, NewString("OK"))
+	assert.Equal(t, "|1\r\n+ttl\r\n:3600\r\n+OK\r\n", string(a.Encode()), "Attribute pairs must be followed by the reply")
+	assert.Equal(t, "OK", a.ToString())
+}
+
+func TestPush(t *testing.T) {
+	p := NewPush(NewString("message"), NewString("chan"), NewString("hi"))
+	assert.Equal(t, 3, p.GetNumberOfItems())
+	assert.Equal(t, ">3\r\n+message\r\n+chan\r\n+hi\r\n", string(p.Encode()))
+}
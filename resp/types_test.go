@@ -1,6 +1,8 @@
 package resp
 
 import (
+	"math"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,6 +16,8 @@ func TestString(t *testing.T) {
 func TestInteger(t *testing.T) {
 	r := NewInteger(123)
 	assert.Equal(t, r.ToString(), "123", "ToString method of Integer must return integer converted to string")
+	r = NewInteger(math.MinInt64)
+	assert.Equal(t, ":-9223372036854775808\r\n", string(r.Encode()), "Integers must keep 64 bits")
 }
 
 func TestRedisError(t *testing.T) {
@@ -38,7 +42,8 @@ func TestBulkString(t *testing.T) {
 	assert.Equal(t, err, nil, "Normal length strings must not trigger error while creating BulkString")
 	var largeStr = string(*makeLargeBytes(MaxBulkSizeLength + 1))
 	_, err = NewBulkString(largeStr)
-	assert.NotEqual(t, err, nil)
+	assert.Nil(t, err, "Only commands are limited to MaxBulkSizeLength")
+	assert.NotNil(t, checkBulkLength(MaxStringLength+1), "BulkString must not be longer than MaxStringLength")
 	bs, _ := NewBulkString("foo")
 	assert.Equal(t, bs.ToString(), "foo", "BulkString must return the underlying string value for non null strings")
 
@@ -47,6 +52,21 @@ func TestBulkString(t *testing.T) {
 	assert.Equal(t, bs.ToString(), "(nil)", "NullBulkString must return (nil) as representation")
 }
 
+func TestLimitedBulkString(t *testing.T) {
+	large := *makeLargeBytes(MaxBulkSizeLength + 1)
+	encoded := append([]byte("$"+strconv.Itoa(len(large))+"\r\n"), large...)
+	_, _, err := parseBulkString(append(encoded, "\r\n"...))
+	assert.Nil(t, err, "Bulk strings of replies must not be limited")
+
+	_, _, err = parseLimitedBulkString([]byte("$4\r\nabcd\r\n"), 3)
+	pe := assertProtocolError(t, err)
+	assert.Equal(t, "invalid bulk length", pe.Reason)
+	_, _, err = parseLimitedBulkString([]byte("$4\r\n"), 3)
+	assertProtocolError(t, err, "Long bulk strings must be rejected before their payload arrives")
+	_, _, err = parseLimitedBulkString([]byte("$3\r\nabc\r\n"), 3)
+	assert.Nil(t, err)
+}
+
 func TestArray(t *testing.T) {
 	// Try invalid size
 	ra, err := NewArray(-1)
@@ -59,3 +79,49 @@ func TestArray(t *testing.T) {
 	ra.SetItemAtIndex(1, bs)
 	assert.Equal(t, ra.GetItemAtIndex(1), bs, "Set item at index must return same item at index")
 }
+
+func TestArrayAppend(t *testing.T) {
+	ra, _ := NewArray(0)
+	ra.Append(NewInteger(1), NewString("two"))
+	assert.Equal(t, 2, ra.GetNumberOfItems())
+	assert.Equal(t, "*2\r\n:1\r\n+two\r\n", string(ra.Encode()))
+
+	ra = NewNullArray()
+	ra.Append(NewInteger(1))
+	assert.False(t, ra.IsNull(), "Appending to a null array must turn it into a regular one")
+	assert.Equal(t, 1, ra.GetNumberOfItems())
+}
+
+func TestEncode(t *testing.T) {
+	assert.Equal(t, "+OK\r\n", string(NewString("OK").Encode()), "String must be encoded as simple string")
+	assert.Equal(t, "-ERR unknown\r\n", string(NewDefaultRedisError("unknown").Encode()), "RedisError must be encoded with ecode and message")
+	assert.Equal(t, "-ERR\r\n", string(NewRedisError("ERR", "").Encode()), "RedisError without message must not have trailing whitespace")
+	assert.Equal(t, "+a  b\r\n", string(NewString("a\r\nb").Encode()), "Line breaks must not end a simple string early")
+	assert.Equal(t, "-ERR Unknown command 'A  +OK'\r\n", string(NewDefaultRedisError("Unknown command 'A\r\n+OK'").Encode()), "Line breaks must not end an error early")
+	assert.Equal(t, ":1\r\n", string(NewInteger(1).Encode()), "Integer must be encoded with : prefix")
+	assert.Equal(t, ":-42\r\n", string(NewInteger(-42).Encode()), "Negative integers must keep their sign")
+	bs, _ := NewBulkString("foo")
+	assert.Equal(t, "$3\r\nfoo\r\n", string(bs.Encode()), "BulkString must be length prefixed")
+	bs, _ = NewBulkString("")
+	assert.Equal(t, "$0\r\n\r\n", string(bs.Encode()), "Empty BulkString must still carry a CRLF terminated payload")
+	assert.Equal(t, "$-1\r\n", string(NewNullBulkString().Encode()), "Null BulkString must be encoded with length -1")
+
+	ra, _ := NewArray(3)
+	ra.SetItemAtIndex(0, NewString("OK"))
+	ra.SetItemAtIndex(1, NewInteger(2))
+	assert.Equal(t, "*3\r\n+OK\r\n:2\r\n$-1\r\n", string(ra.Encode()), "Array must encode each item, unset items as null")
+	ra, _ = NewArray(0)
+	assert.Equal(t, "*0\r\n", string(ra.Encode()), "Empty Array must be encoded with zero count")
+}
+
+func TestBulkStringBytes(t *testing.T) {
+	binary := []byte{0x00, '\r', '\n', 0xff}
+	bs, err := NewBulkStringFromBytes(binary)
+	assert.Nil(t, err)
+	assert.Equal(t, binary, bs.Bytes(), "BulkString must hold raw bytes unchanged")
+	assert.Equal(t, "$4\r\n\x00\r\n\xff\r\n", string(bs.Encode()), "Binary BulkString must be encoded as is")
+	bs, _ = NewBulkStringFromBytes(nil)
+	assert.Equal(t, false, bs.IsNull(), "Empty BulkString must not be null")
+	assert.Equal(t, []byte{}, bs.Bytes())
+	assert.Nil(t, NewNullBulkString().Bytes(), "Null BulkString must have nil bytes")
+}
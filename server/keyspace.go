@@ -0,0 +1,51 @@
+package server
+
+// Direct access to the keyspace, to seed data and check the outcome of a test
+// without going through RESP. These work on the same keys as the commands.
+
+import (
+	"time"
+)
+
+// Set stores value at key like SET does, removing any time to live
+func (s *Server) Set(key string, value string) {
+	s.state.Keyspace().Store(key, []byte(value))
+}
+
+// Get returns the value at key, and false if the key does not exist
+func (s *Server) Get(key string) (string, bool) {
+	value, ok := s.state.Keyspace().Load(key)
+	return string(value), ok
+}
+
+// Del removes keys like DEL does, and returns how many existed
+func (s *Server) Del(keys ...string) int {
+	deleted := 0
+	for _, key := range keys {
+		if s.state.Keyspace().Delete(key) {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// SetTTL makes key expire after ttl, like EXPIRE does. Returns false if the
+// key does not exist
+func (s *Server) SetTTL(key string, ttl time.Duration) bool {
+	return s.state.Keyspace().ExpireAt(key, time.Now().Add(ttl))
+}
+
+// TTL returns the time key has left to live, and false if the key does not
+// exist or does not expire
+func (s *Server) TTL(key string) (time.Duration, bool) {
+	deadline, ok := s.state.Keyspace().Expiry(key)
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// Keys returns all keys, sorted
+func (s *Server) Keys() []string {
+	return s.state.Keyspace().Keys()
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"golang-redis-mock/client"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerKeyspace(t *testing.T) {
+	s := New(Options{})
+	defer s.Close()
+	conn, err := s.Pipe()
+	assert.Nil(t, err)
+	c := client.NewConn(conn)
+	defer c.Close()
+
+	s.Set("seeded", "value")
+	value, err := client.String(c.Do("GET", "seeded"))
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value, "Seeded keys must be visible to commands")
+
+	_, err = c.Do("SET", "written", "a\x00b")
+	assert.Nil(t, err)
+	got, ok := s.Get("written")
+	assert.True(t, ok)
+	assert.Equal(t, "a\x00b", got)
+	_, ok = s.Get("missing")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"seeded", "written"}, s.Keys())
+
+	_, err = c.Do("SETEX", "expiring", "100", "1")
+	assert.Nil(t, err)
+	ttl, ok := s.TTL("expiring")
+	assert.True(t, ok)
+	assert.InDelta(t, 100, ttl.Seconds(), 1)
+	_, ok = s.TTL("seeded")
+	assert.False(t, ok, "Keys without expiry have no TTL")
+	assert.True(t, s.SetTTL("seeded", time.Minute))
+	assert.False(t, s.SetTTL("missing", time.Minute))
+	s.Set("seeded", "again")
+	_, ok = s.TTL("seeded")
+	assert.False(t, ok, "Set must remove the TTL")
+
+	assert.Equal(t, 2, s.Del("seeded", "written", "missing"))
+	_, err = client.String(c.Do("GET", "seeded"))
+	assert.Equal(t, client.ErrNil, err)
+	assert.Equal(t, []string{"expiring"}, s.Keys())
+}
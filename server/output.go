@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"golang-redis-mock/config"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// errOutputBufferLimit is returned when a reply would take a client beyond
+// its client-output-buffer-limit. The connection is closed then
+var errOutputBufferLimit = errors.New("server: client output buffer limit reached")
+
+// flushTimeout is how long a client closed after a reply, such as the reply
+// to QUIT, gets to read the replies still queued
+const flushTimeout = 5 * time.Second
+
+// outputBuffer queues the replies of a connection, which a goroutine of its
+// own writes out. Commands never wait for clients that are slow to read,
+// instead their replies pile up until the client reads them, or exceeds the
+// client-output-buffer-limit of its class and is disconnected.
+type outputBuffer struct {
+	conn net.Conn
+	// Limits of the client, checked whenever a reply is queued. Without
+	// limits the buffer grows as needed
+	limit func() config.OutputBufferLimit
+
+	mu   sync.Mutex
+	wake *sync.Cond
+	// Replies not written yet, and their size in bytes. Replies being
+	// written still count
+	pending [][]byte
+	count   int
+	size    int64
+	// When the buffer was first found at the soft limit, zero if it is below
+	softSince time.Time
+	closed    bool
+	// Set once writing failed or the limits were exceeded
+	err error
+	// Closed once the writer is done
+	done chan struct{}
+}
+
+// Create the output buffer of conn, and start writing to it
+func newOutputBuffer(conn net.Conn) *outputBuffer {
+	ob := &outputBuffer{conn: conn, done: make(chan struct{})}
+	ob.wake = sync.NewCond(&ob.mu)
+	go ob.run()
+	return ob
+}
+
+// Write queues p to be sent, unless that takes the client beyond its limits
+func (ob *outputBuffer) Write(p []byte) (int, error) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	if ob.err != nil {
+		return 0, ob.err
+	}
+	if ob.closed {
+		return 0, io.ErrClosedPipe
+	}
+	ob.pending = append(ob.pending, append([]byte(nil), p...))
+	ob.count++
+	ob.size += int64(len(p))
+	if ob.limit != nil && ob.exceeded(ob.limit(), time.Now()) {
+		// Like Redis, drop the replies and close the connection right away
+		ob.err = errOutputBufferLimit
+		ob.pending = nil
+		ob.conn.Close()
+		ob.wake.Signal()
+		return 0, ob.err
+	}
+	ob.wake.Signal()
+	return len(p), nil
+}
+
+// Check the size of the buffer against limit. Like in Redis, the soft limit
+// is only exceeded if the buffer is still at it more than its seconds after
+// it was first found there
+func (ob *outputBuffer) exceeded(limit config.OutputBufferLimit, now time.Time) bool {
+	if limit.Hard > 0 && ob.size >= limit.Hard {
+		return true
+	}
+	if limit.Soft == 0 || ob.size < limit.Soft {
+		ob.softSince = time.Time{}
+		return false
+	}
+	if ob.softSince.IsZero() {
+		ob.softSince = now
+		return false
+	}
+	return now.Sub(ob.softSince) > time.Duration(limit.SoftSeconds)*time.Second
+}
+
+// Pending returns the number of replies and bytes not written yet
+func (ob *outputBuffer) Pending() (int, int64) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	return ob.count, ob.size
+}
+
+// Write the queued replies until the buffer is closed and drained, or
+// writing fails. Replies queued while writing go out together
+func (ob *outputBuffer) run() {
+	defer close(ob.done)
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	for {
+		for len(ob.pending) == 0 && !ob.closed && ob.err == nil {
+			ob.wake.Wait()
+		}
+		if ob.err != nil || len(ob.pending) == 0 {
+			return
+		}
+		pending := net.Buffers(ob.pending)
+		count := len(ob.pending)
+		ob.pending = nil
+		ob.mu.Unlock()
+		written, err := pending.WriteTo(ob.conn)
+		ob.mu.Lock()
+		ob.count -= count
+		ob.size -= written
+		if err != nil {
+			if ob.err == nil {
+				ob.err = err
+			}
+			// Wake up the reader of the connection as well
+			ob.conn.Close()
+			return
+		}
+	}
+}
+
+// Close writes the replies still queued, giving the client wait to read
+// them, and stops the writer. It returns once they are written or writing
+// failed, so a client that stopped reading cannot hold on to the connection
+func (ob *outputBuffer) Close(wait time.Duration) {
+	ob.mu.Lock()
+	ob.closed = true
+	ob.wake.Signal()
+	ob.mu.Unlock()
+	ob.conn.SetWriteDeadline(time.Now().Add(wait))
+	<-ob.done
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"golang-redis-mock/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputBufferSoftLimit(t *testing.T) {
+	limit := config.OutputBufferLimit{Soft: 100, SoftSeconds: 2}
+	now := time.Now()
+	ob := &outputBuffer{size: 150}
+	assert.False(t, ob.exceeded(limit, now), "The soft limit must not disconnect right away")
+	assert.False(t, ob.exceeded(limit, now.Add(2*time.Second)))
+	assert.True(t, ob.exceeded(limit, now.Add(3*time.Second)), "The soft limit must disconnect after its seconds")
+
+	ob.size = 50
+	assert.False(t, ob.exceeded(limit, now.Add(4*time.Second)))
+	ob.size = 150
+	assert.False(t, ob.exceeded(limit, now.Add(5*time.Second)), "Going below the soft limit must restart the time")
+
+	limit.Hard = 150
+	assert.True(t, ob.exceeded(limit, now.Add(5*time.Second)), "The hard limit must disconnect right away")
+	assert.False(t, ob.exceeded(config.OutputBufferLimit{}, now.Add(time.Hour)), "Zero limits must not disconnect")
+}
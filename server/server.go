@@ -0,0 +1,554 @@
+// Package server runs the mock. It serves the redis-mock-server command, and
+// can be embedded in Go tests, where every Server has its own keyspace:
+//
+//	s := server.New(server.Options{})
+//	if err := s.Start(); err != nil {
+//		t.Fatal(err)
+//	}
+//	defer s.Close()
+//	c, err := client.Dial("tcp", s.Addr())
+package server
+
+// https://coderwall.com/p/wohavg/creating-a-simple-tcp-server-in-go
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"golang-redis-mock/commands"
+	"golang-redis-mock/config"
+	"golang-redis-mock/resp"
+	"net"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrServerStarted is returned when starting a server twice
+var ErrServerStarted = errors.New("server: already started")
+
+// ErrServerClosed is returned when connecting to a server that was shut down
+var ErrServerClosed = errors.New("server: closed")
+
+// Sent to connections beyond maxclients before closing them
+var errMaxClients = resp.NewDefaultRedisError("max number of clients reached")
+
+// Runs the commands of clients, replaced by tests
+var executeCommand = commands.ExecuteCommand
+
+// Options configures a Server
+type Options struct {
+	// Configuration of the server. Defaults to config.Default, listening on
+	// a random port of 127.0.0.1 instead
+	Config *config.Config
+	// Listener, if set, is served by Start instead of listening on the bind
+	// addresses and port of Config. It is closed when the server shuts down
+	Listener net.Listener
+}
+
+// Server accepts connections on its listeners and serves them until it is
+// shut down
+type Server struct {
+	store *config.Store
+	state *commands.State
+	// Served instead of the configured addresses, if set
+	listener net.Listener
+
+	mu        sync.Mutex
+	started   bool
+	listeners []net.Listener
+	// The listeners speaking TLS, also found in listeners
+	tlsListeners []net.Listener
+	// Open connections
+	conns map[net.Conn]struct{}
+	// Connections admitted as clients, at most maxclients
+	clients int
+	// Closed when the server starts shutting down
+	quit     chan struct{}
+	stopping bool
+	// Counts the connections being served
+	wg sync.WaitGroup
+	// Receives the options of the first shutdown request
+	shutdownRequests chan commands.ShutdownOptions
+	// Closed once the server is shut down
+	done chan struct{}
+}
+
+// New creates a server configured by opts. It does not listen until Start
+// is called, but Pipe can connect to it right away
+func New(opts Options) *Server {
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = config.Default()
+		cfg.Bind = []string{"127.0.0.1"}
+		cfg.Port = 0
+	}
+	store := config.NewStore(cfg)
+	s := &Server{
+		store:            store,
+		state:            commands.NewState(store),
+		listener:         opts.Listener,
+		conns:            map[net.Conn]struct{}{},
+		quit:             make(chan struct{}),
+		shutdownRequests: make(chan commands.ShutdownOptions, 1),
+		done:             make(chan struct{}),
+	}
+	s.state.HandleShutdown(s.requestShutdown)
+	go s.wait()
+	return s
+}
+
+// Start loads the users of the aclfile, listens on every bind address, the
+// unix socket and the TLS port, or the listener of the options, and serves
+// connections in the background, until the server is shut down by Close,
+// Shutdown or the SHUTDOWN command
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return ErrServerClosed
+	}
+	if s.started {
+		return ErrServerStarted
+	}
+	if err := s.state.LoadACLFile(); err != nil {
+		return err
+	}
+	listeners, tlsListeners, err := s.listen()
+	if err != nil {
+		return err
+	}
+	s.started = true
+	s.listeners = append(listeners, tlsListeners...)
+	s.tlsListeners = tlsListeners
+	s.state.SetTCPPort(tcpPort(listeners))
+	for _, l := range s.listeners {
+		go s.serve(l)
+	}
+	return nil
+}
+
+// Create the listeners the server should accept connections on. TLS
+// listeners are returned separately, and should be served as well
+func (s *Server) listen() ([]net.Listener, []net.Listener, error) {
+	if s.listener != nil {
+		return []net.Listener{s.listener}, nil, nil
+	}
+	cfg := s.store.Load()
+	var listeners, tlsListeners []net.Listener
+	fail := func(err error) ([]net.Listener, []net.Listener, error) {
+		for _, l := range append(listeners, tlsListeners...) {
+			l.Close()
+		}
+		return nil, nil, err
+	}
+	for _, host := range cfg.Bind {
+		l, err := s.listenTCP(host, cfg.Port)
+		if err != nil {
+			return fail(err)
+		}
+		listeners = append(listeners, l)
+	}
+	if cfg.UnixSocket != "" {
+		l, err := listenUnix(cfg.UnixSocket, cfg.UnixSocketPerm)
+		if err != nil {
+			return fail(err)
+		}
+		listeners = append(listeners, l)
+	}
+	// Like in Redis, tls-port 0 turns TLS off
+	if cfg.TLSPort == 0 {
+		return listeners, nil, nil
+	}
+	if cfg.TLSCertFile == "" {
+		return fail(errors.New("tls-port requires tls-cert-file and tls-key-file"))
+	}
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return fail(err)
+	}
+	for _, host := range cfg.Bind {
+		l, err := s.listenTCP(host, cfg.TLSPort)
+		if err != nil {
+			return fail(err)
+		}
+		tlsListeners = append(tlsListeners, tls.NewListener(l, tlsConfig))
+	}
+	return listeners, tlsListeners, nil
+}
+
+// Find the port of the first TCP listener, or 0 without any
+func tcpPort(listeners []net.Listener) int {
+	for _, l := range listeners {
+		if addr, ok := l.Addr().(*net.TCPAddr); ok {
+			return addr.Port
+		}
+	}
+	return 0
+}
+
+// Listen on a TCP port of host. Accepted connections use the keepalive
+// settings of tcp-keepalive
+func (s *Server) listenTCP(host string, port int) (net.Listener, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	return keepAliveListener{l.(*net.TCPListener), s.store}, nil
+}
+
+// keepAliveListener sets the TCP keepalive of the connections it accepts.
+// tcp-keepalive may change with CONFIG SET, and applies to new connections
+type keepAliveListener struct {
+	*net.TCPListener
+	store *config.Store
+}
+
+func (l keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	if period := l.store.Load().TCPKeepAlive; period > 0 {
+		conn.SetKeepAlive(true)
+		conn.SetKeepAlivePeriod(time.Duration(period) * time.Second)
+	} else {
+		conn.SetKeepAlive(false)
+	}
+	return conn, nil
+}
+
+// Listen on a unix socket at path, with the given permissions unless they
+// are 0. A socket left behind by a previous run is replaced, like Redis does
+func listenUnix(path string, perm int) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if perm != 0 {
+		if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Pipe connects to the server through net.Pipe, without any networking. The
+// connection is served like those accepted from listeners, and the server
+// does not have to be started for it
+func (s *Server) Pipe() (net.Conn, error) {
+	serverConn, clientConn := net.Pipe()
+	if !s.track(serverConn) {
+		serverConn.Close()
+		clientConn.Close()
+		return nil, ErrServerClosed
+	}
+	go s.handleRequest(serverConn)
+	return clientConn, nil
+}
+
+// Addr returns the address of the first listener, like 127.0.0.1:6382 or
+// the path of the unix socket. With port 0 this is where the system picked
+// the port
+func (s *Server) Addr() string {
+	addrs := s.Addrs()
+	if len(addrs) == 0 {
+		return ""
+	}
+	return addrs[0]
+}
+
+// Addrs returns the addresses of all listeners, TLS ones last
+func (s *Server) Addrs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return listenerAddrs(s.listeners)
+}
+
+// TLSAddr returns the address of the first TLS listener, or an empty string
+// without TLS
+func (s *Server) TLSAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := listenerAddrs(s.tlsListeners)
+	if len(addrs) == 0 {
+		return ""
+	}
+	return addrs[0]
+}
+
+// Get the addresses of listeners
+func listenerAddrs(listeners []net.Listener) []string {
+	addrs := make([]string, len(listeners))
+	for i, l := range listeners {
+		addrs[i] = l.Addr().String()
+	}
+	return addrs
+}
+
+// Shutdown asks the server to shut down, the way SHUTDOWN does, and returns
+// without waiting for it
+func (s *Server) Shutdown(options commands.ShutdownOptions) error {
+	return s.requestShutdown(options)
+}
+
+// Wait blocks until the server is shut down
+func (s *Server) Wait() {
+	<-s.done
+}
+
+// Close shuts the server down and waits until it is done
+func (s *Server) Close() error {
+	s.requestShutdown(commands.ShutdownOptions{})
+	<-s.done
+	return nil
+}
+
+// Ask the server to shut down. The shutdown itself runs in wait
+func (s *Server) requestShutdown(options commands.ShutdownOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case s.shutdownRequests <- options:
+	default:
+		// A shutdown was already requested
+	}
+	return nil
+}
+
+// Accept connections on l until the server shuts down. Errors other than
+// temporary ones shut the server down
+func (s *Server) serve(l net.Listener) {
+	// How long to wait before accepting again after a temporary error
+	var delay time.Duration
+	for {
+		// Listen for an incoming connection.
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+				// The listener was closed by shutdown
+				return
+			default:
+			}
+			// Like net/http, ride out errors such as running out of file
+			// descriptors, backing off up to a second
+			if ne, ok := err.(interface{ Temporary() bool }); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				fmt.Printf("Error accepting: %s, retrying in %v\n", err.Error(), delay)
+				select {
+				case <-time.After(delay):
+					continue
+				case <-s.quit:
+					return
+				}
+			}
+			fmt.Println("Error accepting: ", err.Error())
+			s.requestShutdown(commands.ShutdownOptions{})
+			return
+		}
+		delay = 0
+		if !s.track(conn) {
+			conn.Close()
+			return
+		}
+		// Handle connections in a new goroutine.
+		go s.handleRequest(conn)
+	}
+}
+
+// Add a connection to the ones served, unless the server is shutting down
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// Count a connection as a client, unless maxclients are connected already
+func (s *Server) admit() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients >= s.store.Load().MaxClients {
+		return false
+	}
+	s.clients++
+	return true
+}
+
+// Stop counting a client that disconnected
+func (s *Server) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients--
+}
+
+// Forget a connection that was closed
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// Set the deadline for reading the next command. Returns false if the
+// server is shutting down, and the connection should be closed instead
+func (s *Server) waitForCommand(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return false
+	}
+	// The timeout may change with CONFIG SET, so look it up every time
+	if timeout := s.store.Load().Timeout; timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
+	} else {
+		conn.SetReadDeadline(time.Time{})
+	}
+	return true
+}
+
+// Handles incoming requests.
+func (s *Server) handleRequest(conn net.Conn) {
+	defer s.untrack(conn)
+	// Create a new reader. It buffers partial commands until they are complete
+	reader := resp.NewReader(conn)
+	// Replies are queued in the output buffer, which writes them out on its
+	// own
+	out := newOutputBuffer(conn)
+	// Every reply goes out as RESP
+	encoder := resp.NewEncoder(out)
+	// Like Redis, tell clients beyond maxclients why they are disconnected
+	if !s.admit() {
+		s.state.RejectConnection()
+		encoder.Encode(errMaxClients)
+		out.Close(flushTimeout)
+		conn.Close()
+		return
+	}
+	defer s.release()
+	session := commands.NewSession(s.state, conn)
+	defer session.Close()
+	// How long the client gets to read the replies still queued once the
+	// connection is done with, such as the reply to QUIT. The socket is
+	// closed before the client is forgotten, so it counts towards
+	// maxclients and shows in CLIENT LIST until then
+	flush := flushTimeout
+	defer func() {
+		out.Close(flush)
+		conn.Close()
+	}()
+	session.SetOutputBuffer(out)
+	out.limit = func() config.OutputBufferLimit {
+		return s.store.Load().ClientOutputBufferLimits[session.Class()]
+	}
+	// A command that panics must not take down the process, which may be
+	// the tests embedding the server. Like for protocol errors, the client
+	// gets an error and only its connection is closed
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Panic serving %s: %v\n%s", conn.RemoteAddr(), r, debug.Stack())
+			encoder.Encode(resp.NewDefaultRedisError(fmt.Sprintf("internal error: %v", r)))
+		}
+	}()
+	for s.waitForCommand(conn) {
+		// The limit may change with CONFIG SET as well
+		reader.SetMaxBulkLength(s.store.Load().ProtoMaxBulkLen)
+		ra, f := reader.ReadCommand()
+		if f != nil {
+			if pe, ok := f.(*resp.ProtocolError); ok {
+				// Like Redis, reply with the error and close the connection,
+				// the rest of the stream cannot be trusted
+				encoder.Encode(pe.RedisError())
+			}
+			// Otherwise the connection was closed or failed. Idle clients
+			// timed out may not be reading either, so their replies are
+			// dropped
+			if ne, ok := f.(net.Error); ok && ne.Timeout() {
+				flush = 0
+			}
+			return
+		}
+		session.SetQueryBuffer(reader.Buffered())
+		dataType, err := executeCommand(session, *ra)
+		if session.Closing() {
+			return
+		}
+		// HELLO may have switched protocols, the reply already uses the new one
+		encoder.SetProtocol(session.Protocol())
+		if err != resp.EmptyRedisError {
+			dataType = err
+		}
+		if e := encoder.Encode(dataType); e != nil {
+			// Clients beyond their output buffer limits are disconnected
+			if e == errOutputBufferLimit {
+				s.state.DisconnectClient()
+			}
+			return
+		}
+		if session.CloseAfterReply() {
+			return
+		}
+	}
+}
+
+// Wait until the server is asked to shut down, then shut it down
+func (s *Server) wait() {
+	s.shutdown(<-s.shutdownRequests)
+	s.state.Close()
+	close(s.done)
+}
+
+// Shut the server down: stop accepting connections, let every connection
+// finish the command it is running, then close them. Connections still busy
+// after shutdown-timeout seconds, or right away with NOW, are closed as they
+// are.
+func (s *Server) shutdown(options commands.ShutdownOptions) {
+	s.mu.Lock()
+	s.stopping = true
+	close(s.quit)
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	// Wake up connections waiting for a command. Those running one notice
+	// the shutdown once they replied
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	timeout := time.Duration(s.store.Load().ShutdownTimeout) * time.Second
+	if options.Now {
+		timeout = 0
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		<-done
+	}
+	// The data only lives in memory, so there is nothing to save
+}
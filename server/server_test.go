@@ -0,0 +1,520 @@
+package server
+
+import (
+	"fmt"
+	"golang-redis-mock/client"
+	"golang-redis-mock/commands"
+	"golang-redis-mock/config"
+	"golang-redis-mock/resp"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a server on a random local port
+func startServer(t *testing.T) *Server {
+	s := New(Options{})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Wait until s is shut down, or fail the test
+func waitForShutdown(t *testing.T, s *Server) {
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The server did not shut down")
+	}
+}
+
+func TestServerStartClose(t *testing.T) {
+	s := startServer(t)
+	assert.NotEqual(t, "127.0.0.1:6382", s.Addr(), "The default port must not be used")
+	assert.Equal(t, ErrServerStarted, s.Start())
+	c, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Do("SET", "foo", "bar")
+	assert.Nil(t, err)
+	assert.Nil(t, s.Close())
+	assert.Nil(t, s.Close(), "Closing twice must be harmless")
+	_, err = c.Do("GET", "foo")
+	assert.NotNil(t, err)
+	_, err = net.Dial("tcp", s.Addr())
+	assert.NotNil(t, err, "The server must stop accepting connections")
+
+	assert.Nil(t, New(Options{}).Close(), "Servers that were never started can be closed")
+}
+
+func TestServerKeyspacePerInstance(t *testing.T) {
+	first := startServer(t)
+	defer first.Close()
+	second := startServer(t)
+	defer second.Close()
+	c1, err := client.Dial("tcp", first.Addr())
+	assert.Nil(t, err)
+	defer c1.Close()
+	c2, err := client.Dial("tcp", second.Addr())
+	assert.Nil(t, err)
+	defer c2.Close()
+	_, err = c1.Do("SET", "foo", "first")
+	assert.Nil(t, err)
+	_, err = client.String(c2.Do("GET", "foo"))
+	assert.Equal(t, client.ErrNil, err, "Servers must not share keys")
+	_, err = c2.Do("SET", "foo", "second")
+	assert.Nil(t, err)
+	value, err := client.String(c1.Do("GET", "foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, "first", value)
+}
+
+func TestShutdownCommand(t *testing.T) {
+	s := startServer(t)
+	idle, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer idle.Close()
+	_, err = idle.Do("SET", "shutdown", "1")
+	assert.Nil(t, err)
+
+	c, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Do("SHUTDOWN", "ABORT")
+	assert.Equal(t, "ERR syntax error", err.Error(), "Shutdowns cannot be aborted")
+	_, err = c.Do("SHUTDOWN", "SAVE", "NOSAVE")
+	assert.Equal(t, "ERR syntax error", err.Error())
+	_, err = c.Do("SHUTDOWN", "NOSAVE")
+	assert.NotNil(t, err, "SHUTDOWN must close the connection without a reply")
+	_, ok := err.(resp.RedisError)
+	assert.False(t, ok, "SHUTDOWN must not reply with an error")
+
+	waitForShutdown(t, s)
+	_, err = idle.Do("GET", "shutdown")
+	assert.NotNil(t, err, "Idle connections must be closed")
+}
+
+func TestShutdownClosesConnections(t *testing.T) {
+	s := startServer(t)
+	c, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Do("SET", "signal", "1")
+	assert.Nil(t, err)
+	assert.Nil(t, s.Shutdown(commands.ShutdownOptions{}))
+	waitForShutdown(t, s)
+	_, err = c.Do("GET", "signal")
+	assert.NotNil(t, err)
+}
+
+func TestShutdownPartialCommand(t *testing.T) {
+	s := startServer(t)
+	conn, err := net.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+	// Half a command must not hold up the shutdown until shutdown-timeout
+	conn.Write([]byte("*2\r\n$3\r\nGET\r\n"))
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	s.Close()
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+// A listener handing out the server ends of net.Pipe connections
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, ErrServerClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+func (l *pipeListener) dial() net.Conn {
+	serverConn, clientConn := net.Pipe()
+	l.conns <- serverConn
+	return clientConn
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+func TestServerListener(t *testing.T) {
+	l := newPipeListener()
+	s := New(Options{Listener: l})
+	assert.Nil(t, s.Start())
+	assert.Equal(t, "pipe", s.Addr())
+	c := client.NewConn(l.dial())
+	defer c.Close()
+	_, err := c.Do("SET", "foo", "bar")
+	assert.Nil(t, err)
+	value, err := client.String(c.Do("GET", "foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", value)
+	s.Close()
+	select {
+	case <-l.closed:
+	default:
+		t.Fatal("The listener must be closed on shutdown")
+	}
+}
+
+// A temporary error, like the EMFILE of running out of file descriptors
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Temporary() bool { return true }
+func (temporaryError) Timeout() bool   { return false }
+
+// A listener failing the first accepts with temporary errors
+type failingListener struct {
+	*pipeListener
+	failures int
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, temporaryError{}
+	}
+	return l.pipeListener.Accept()
+}
+
+func TestServerAcceptTemporaryError(t *testing.T) {
+	l := &failingListener{pipeListener: newPipeListener(), failures: 3}
+	s := New(Options{Listener: l})
+	assert.Nil(t, s.Start())
+	defer s.Close()
+	c := client.NewConn(l.dial())
+	defer c.Close()
+	_, err := c.Do("SET", "foo", "bar")
+	assert.Nil(t, err, "Temporary errors must not stop the server")
+}
+
+func TestServerPipe(t *testing.T) {
+	s := New(Options{})
+	conn, err := s.Pipe()
+	assert.Nil(t, err)
+	c := client.NewConn(conn)
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, c.Send("INCR", "pipe"))
+	}
+	assert.Nil(t, c.Flush())
+	for i := 0; i < 100; i++ {
+		_, err := c.Receive()
+		assert.Nil(t, err)
+	}
+	n, err := client.Int(c.Do("GET", "pipe"))
+	assert.Nil(t, err)
+	assert.Equal(t, 100, n)
+	assert.Equal(t, "", s.Addr(), "Pipes must not need a listener")
+
+	s.Close()
+	_, err = c.Do("GET", "pipe")
+	assert.NotNil(t, err, "Pipes must be closed on shutdown")
+	_, err = s.Pipe()
+	assert.Equal(t, ErrServerClosed, err)
+	assert.Equal(t, ErrServerClosed, s.Start())
+}
+
+func TestServerProtoMaxBulkLen(t *testing.T) {
+	cfg := config.Default()
+	cfg.ProtoMaxBulkLen = 2 * 1024 * 1024
+	raised := New(Options{Config: cfg})
+	defer raised.Close()
+	s := New(Options{})
+	defer s.Close()
+	value := make([]byte, 1536*1024)
+
+	conn, err := raised.Pipe()
+	assert.Nil(t, err)
+	c := client.NewConn(conn)
+	defer c.Close()
+	_, err = c.Do("SET", "large", value)
+	assert.Nil(t, err)
+	reply, err := c.Do("GET", "large")
+	assert.Nil(t, err, "Clients must read replies beyond the limit of the server")
+	assert.Len(t, reply.ToString(), len(value))
+
+	// The header is enough for the error. Pipes have no buffer, so the
+	// payload could not be written while the server replies
+	conn, err = s.Pipe()
+	assert.Nil(t, err)
+	defer conn.Close()
+	fmt.Fprintf(conn, "*3\r\n$3\r\nSET\r\n$5\r\nlarge\r\n$%d\r\n", len(value))
+	reply, err = resp.NewReader(conn).ReadValue()
+	assert.Nil(t, err)
+	assert.Equal(t, resp.NewDefaultRedisError("Protocol error: invalid bulk length"), reply, "Servers must keep their own limits")
+}
+
+func TestServerPanic(t *testing.T) {
+	defer func(execute func(*commands.Session, resp.Array) (resp.IDataType, resp.RedisError)) {
+		executeCommand = execute
+	}(executeCommand)
+	executeCommand = func(session *commands.Session, ra resp.Array) (resp.IDataType, resp.RedisError) {
+		if ra.GetItemAtIndex(0).ToString() == "BOOM" {
+			panic("boom")
+		}
+		return commands.ExecuteCommand(session, ra)
+	}
+	s := New(Options{})
+	defer s.Close()
+	conn, err := s.Pipe()
+	assert.Nil(t, err)
+	c := client.NewConn(conn)
+	defer c.Close()
+	_, err = c.Do("BOOM")
+	assert.Equal(t, resp.NewDefaultRedisError("internal error: boom"), err, "Panics must be reported to the client")
+	_, err = c.Do("GET", "k")
+	assert.NotNil(t, err, "The connection of a command that panics must be closed")
+
+	conn, err = s.Pipe()
+	assert.Nil(t, err)
+	other := client.NewConn(conn)
+	defer other.Close()
+	_, err = other.Do("APPEND", "k")
+	assert.Equal(t, resp.NewDefaultRedisError("wrong number of arguments for (append) command"), err)
+	n, err := client.Int(other.Do("APPEND", "k", "v"))
+	assert.Nil(t, err, "Other connections must still be served")
+	assert.Equal(t, 1, n)
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redis-mock-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := config.Default()
+	cfg.Bind = []string{"127.0.0.1"}
+	cfg.Port = 0
+	cfg.UnixSocket = filepath.Join(dir, "redis.sock")
+	cfg.UnixSocketPerm = 0700
+	s := New(Options{Config: cfg})
+	assert.Nil(t, s.Start())
+	defer s.Close()
+	addrs := s.Addrs()
+	assert.Equal(t, 2, len(addrs))
+	assert.Equal(t, cfg.UnixSocket, addrs[1])
+	info, err := os.Stat(cfg.UnixSocket)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	unix, err := client.Dial("unix", cfg.UnixSocket)
+	assert.Nil(t, err)
+	defer unix.Close()
+	tcp, err := client.Dial("tcp", addrs[0])
+	assert.Nil(t, err)
+	defer tcp.Close()
+	_, err = unix.Do("SET", "foo", "unix")
+	assert.Nil(t, err)
+	value, err := client.String(tcp.Do("GET", "foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, "unix", value, "Both listeners must share the keyspace")
+
+	// Without bind addresses, only the unix socket is served
+	s.Close()
+	cfg.Bind = nil
+	unixOnly := New(Options{Config: cfg})
+	assert.Nil(t, unixOnly.Start())
+	defer unixOnly.Close()
+	assert.Equal(t, []string{cfg.UnixSocket}, unixOnly.Addrs())
+}
+
+func TestServerACLFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redis-mock-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := config.Default()
+	cfg.Bind = []string{"127.0.0.1"}
+	cfg.Port = 0
+	cfg.ACLFile = filepath.Join(dir, "users.acl")
+	assert.True(t, os.IsNotExist(New(Options{Config: cfg}).Start()), "A missing aclfile must fail the start")
+
+	ioutil.WriteFile(cfg.ACLFile, []byte("user default on >admin ~* +@all\nuser app on >app ~app:* +@all -@dangerous\n"), 0600)
+	s := New(Options{Config: cfg})
+	assert.Nil(t, s.Start())
+	defer s.Close()
+	c, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Do("GET", "foo")
+	assert.Equal(t, "NOAUTH Authentication required.", err.Error())
+	_, err = c.Do("AUTH", "app", "app")
+	assert.Nil(t, err)
+	_, err = c.Do("SET", "app:foo", "bar")
+	assert.Nil(t, err)
+	_, err = c.Do("CONFIG", "GET", "port")
+	assert.Equal(t, "NOPERM User app has no permissions to run the 'config' command", err.Error())
+}
+
+func TestServerTimeout(t *testing.T) {
+	s := startServer(t)
+	defer s.Close()
+	c, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Do("CONFIG", "SET", "timeout", "1")
+	assert.Nil(t, err)
+	time.Sleep(500 * time.Millisecond)
+	_, err = c.Do("SET", "foo", "bar")
+	assert.Nil(t, err, "Active clients must not time out")
+	time.Sleep(1500 * time.Millisecond)
+	_, err = c.Do("GET", "foo")
+	assert.NotNil(t, err, "Idle clients must be disconnected")
+	_, isReply := err.(resp.RedisError)
+	assert.False(t, isReply)
+}
+
+func TestServerTimeoutNotReading(t *testing.T) {
+	s := startServer(t)
+	defer s.Close()
+	s.Set("big", strings.Repeat("x", 8<<20))
+	c, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Do("CONFIG", "SET", "timeout", "1")
+	assert.Nil(t, err)
+
+	// The replies fill the socket buffers, since they are never read
+	conn, err := net.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+	conn.Write([]byte(strings.Repeat("GET big\r\n", 4)))
+	var list string
+	for i := 0; i < 300 && strings.Count(list, "\n") != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+		list, _ = client.String(c.Do("CLIENT", "LIST"))
+	}
+	assert.Equal(t, 1, strings.Count(list, "\n"), "Clients that stop reading must time out")
+	s.mu.Lock()
+	open := len(s.conns)
+	s.mu.Unlock()
+	assert.Equal(t, 1, open, "The connection must be closed once the client is gone")
+
+	// What was written before the timeout is followed by the end of the stream
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = ioutil.ReadAll(conn)
+	if ne, ok := err.(net.Error); ok {
+		assert.False(t, ne.Timeout(), "The connection must be closed")
+	}
+}
+
+func TestServerMaxClients(t *testing.T) {
+	cfg := config.Default()
+	cfg.Bind = []string{"127.0.0.1"}
+	cfg.Port = 0
+	cfg.MaxClients = 1
+	s := New(Options{Config: cfg})
+	assert.Nil(t, s.Start())
+	defer s.Close()
+	first, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	_, err = first.Do("SET", "foo", "bar")
+	assert.Nil(t, err)
+
+	// The error is sent right away, read it before sending anything
+	conn, err := net.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+	reply, err := resp.NewReader(conn).ReadValue()
+	assert.Nil(t, err)
+	assert.Equal(t, "ERR max number of clients reached", reply.ToString())
+	info, _ := client.String(first.Do("INFO", "stats"))
+	assert.Contains(t, info, "rejected_connections:1\r\n")
+
+	// The slot of a client is free once it disconnects
+	first.Close()
+	var second *client.Conn
+	for i := 0; i < 50; i++ {
+		second, err = client.Dial("tcp", s.Addr())
+		assert.Nil(t, err)
+		if _, err = second.Do("GET", "foo"); err == nil {
+			break
+		}
+		second.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, err)
+	defer second.Close()
+}
+
+func TestServerOutputBufferLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.ClientOutputBufferLimits[config.ClientNormal] = config.OutputBufferLimit{Hard: 1 << 20}
+	s := New(Options{Config: cfg})
+	defer s.Close()
+	conn, err := s.Pipe()
+	assert.Nil(t, err)
+	c := client.NewConn(conn)
+	defer c.Close()
+	_, err = c.Do("SET", "big", strings.Repeat("x", 256<<10))
+	assert.Nil(t, err)
+
+	// Pipes have no buffer, replies wait in the output buffer until read
+	conn, err = s.Pipe()
+	assert.Nil(t, err)
+	stalled := client.NewConn(conn)
+	defer stalled.Close()
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, stalled.Send("GET", "big"))
+	}
+	assert.Nil(t, stalled.Flush())
+	var list string
+	for i := 0; i < 50 && !strings.Contains(list, " oll=3 "); i++ {
+		time.Sleep(10 * time.Millisecond)
+		list, _ = client.String(c.Do("CLIENT", "LIST"))
+	}
+	assert.Contains(t, list, " oll=3 omem=786", "CLIENT LIST must show the replies waiting")
+
+	// The fourth reply takes the client beyond the hard limit
+	assert.Nil(t, stalled.Send("GET", "big"))
+	assert.Nil(t, stalled.Flush())
+	for i := 0; i < 4 && err == nil; i++ {
+		_, err = stalled.Receive()
+	}
+	assert.NotNil(t, err, "Clients beyond the hard limit must be disconnected")
+	var info string
+	for i := 0; i < 50 && !strings.Contains(info, "client_output_buffer_limit_disconnections:1\r\n"); i++ {
+		time.Sleep(10 * time.Millisecond)
+		info, _ = client.String(c.Do("INFO", "stats"))
+	}
+	assert.Contains(t, info, "client_output_buffer_limit_disconnections:1\r\n")
+	_, err = c.Do("GET", "big")
+	assert.Nil(t, err, "Other clients must not be affected")
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"golang-redis-mock/config"
+	"io/ioutil"
+	"strings"
+)
+
+// newTLSConfig builds the TLS configuration of the tls-* parameters
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.TLSKeyFile == "" {
+		return nil, errors.New("tls-key-file must be set along with tls-cert-file")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.TLSProtocols != "" {
+		tlsConfig.MinVersion, tlsConfig.MaxVersion = 0, 0
+		for _, protocol := range strings.Fields(cfg.TLSProtocols) {
+			version := config.TLSVersions[protocol]
+			if tlsConfig.MinVersion == 0 || version < tlsConfig.MinVersion {
+				tlsConfig.MinVersion = version
+			}
+			if version > tlsConfig.MaxVersion {
+				tlsConfig.MaxVersion = version
+			}
+		}
+	}
+	switch cfg.TLSAuthClients {
+	case "no":
+		tlsConfig.ClientAuth = tls.NoClientCert
+		return tlsConfig, nil
+	case "optional":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	// Client certificates can only be verified against a known authority
+	if cfg.TLSCACertFile == "" {
+		return nil, errors.New("tls-ca-cert-file must be set unless tls-auth-clients is no")
+	}
+	pem, err := ioutil.ReadFile(cfg.TLSCACertFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.TLSCACertFile)
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"golang-redis-mock/client"
+	"golang-redis-mock/config"
+	"golang-redis-mock/resp"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A certificate and its key, signed by a test authority
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// Create a certificate signed by parent, or a self-signed authority without
+// parent
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// Write the certificate and key as PEM files in dir, and return their paths
+func (c *testCert) write(t *testing.T, dir string, name string) (string, string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// Find a free local port. Unlike port, tls-port 0 turns TLS off
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// Start a server with TLS on a free port, configured by setup
+func startTLSServer(t *testing.T, dir string, ca *testCert, setup func(cfg *config.Config)) *Server {
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "server", ca).write(t, dir, "server")
+	cfg := config.Default()
+	cfg.Bind = []string{"127.0.0.1"}
+	cfg.Port = 0
+	cfg.TLSPort = freePort(t)
+	cfg.TLSCertFile = certFile
+	cfg.TLSKeyFile = keyFile
+	cfg.TLSCACertFile = caFile
+	if setup != nil {
+		setup(cfg)
+	}
+	s := New(Options{Config: cfg})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Check that the server refused a TLS connection. The handshake may only fail
+// on the first command, but the command itself must never be answered
+func rejected(c *client.Conn, err error) bool {
+	if err == nil {
+		_, err = c.Do("GET", "foo")
+		c.Close()
+	}
+	_, answered := err.(resp.RedisError)
+	return err != nil && !answered
+}
+
+func TestServerTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "redis-mock-tls")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil)
+	s := startTLSServer(t, dir, ca, nil)
+	defer s.Close()
+	assert.NotEqual(t, "", s.TLSAddr())
+	assert.NotEqual(t, s.Addr(), s.TLSAddr(), "TLS must listen on its own port")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	c, err := client.DialTLS("tcp", s.TLSAddr(), &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{newTestCert(t, "client", ca).tlsCertificate()},
+	})
+	assert.Nil(t, err)
+	defer c.Close()
+	_, err = c.Do("SET", "foo", "tls")
+	assert.Nil(t, err)
+	plain, err := client.Dial("tcp", s.Addr())
+	assert.Nil(t, err)
+	defer plain.Close()
+	value, err := client.String(plain.Do("GET", "foo"))
+	assert.Nil(t, err)
+	assert.Equal(t, "tls", value, "TLS and plain connections must share the keyspace")
+
+	// tls-auth-clients defaults to yes, like in Redis
+	assert.True(t, rejected(client.DialTLS("tcp", s.TLSAddr(), &tls.Config{RootCAs: roots})),
+		"Clients without a certificate must be rejected")
+	assert.True(t, rejected(client.DialTLS("tcp", s.TLSAddr(), &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{newTestCert(t, "stranger", newTestCert(t, "other ca", nil)).tlsCertificate()},
+	})), "Certificates of unknown authorities must be rejected")
+}
+
+func TestServerTLSOptions(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "redis-mock-tls")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil)
+	s := startTLSServer(t, dir, ca, func(cfg *config.Config) {
+		cfg.TLSAuthClients = "no"
+		cfg.TLSProtocols = "TLSv1.3"
+	})
+	defer s.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	c, err := client.DialTLS("tcp", s.TLSAddr(), &tls.Config{RootCAs: roots})
+	assert.Nil(t, err, "Client certificates must not be needed with tls-auth-clients no")
+	defer c.Close()
+	_, err = c.Do("SET", "foo", "bar")
+	assert.Nil(t, err)
+
+	assert.True(t, rejected(client.DialTLS("tcp", s.TLSAddr(), &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS12})),
+		"Versions below the minimum must be rejected")
+}
+
+func TestServerTLSConfigErrors(t *testing.T) {
+	cfg := config.Default()
+	cfg.Bind = []string{"127.0.0.1"}
+	cfg.Port = 0
+	cfg.TLSPort = 6390
+	assert.NotNil(t, New(Options{Config: cfg}).Start(), "tls-port needs a certificate")
+
+	dir, _ := ioutil.TempDir("", "redis-mock-tls")
+	defer os.RemoveAll(dir)
+	certFile, keyFile := newTestCert(t, "ca", nil).write(t, dir, "server")
+	cfg.TLSPort = 0
+	cfg.TLSCertFile = certFile
+	cfg.TLSKeyFile = keyFile
+	s := New(Options{Config: cfg})
+	assert.Nil(t, s.Start(), "The certificate must not be checked with TLS off")
+	assert.Equal(t, "", s.TLSAddr(), "tls-port 0 must turn TLS off")
+	s.Close()
+	cfg.TLSPort = freePort(t)
+	err := New(Options{Config: cfg}).Start()
+	assert.Equal(t, "tls-ca-cert-file must be set unless tls-auth-clients is no", err.Error())
+}
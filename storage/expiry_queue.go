@@ -15,6 +15,9 @@ type ExpiryQueue struct {
 	sortedKeys []string
 	// Write to this channel when a key is expired
 	out chan string
+	// Closed to stop expiring keys
+	done      chan struct{}
+	closeOnce sync.Once
 	// A mutex to make sure that sortedKeys is not modified concurrently
 	mux sync.Mutex
 }
@@ -25,6 +28,7 @@ func NewExpiryQueue() *ExpiryQueue {
 		ttlMap:     make(map[string]int64),
 		sortedKeys: make([]string, 0),
 		out:        make(chan string),
+		done:       make(chan struct{}),
 	}
 	go eq.expireKeys()
 	return &eq
@@ -34,6 +38,13 @@ func NewExpiryQueue() *ExpiryQueue {
 func (eq *ExpiryQueue) insertKey(x string, ttl int64) {
 	eq.mux.Lock()
 	defer eq.mux.Unlock()
+	// A key is queued once, at its latest expiry
+	for i, k := range eq.sortedKeys {
+		if k == x {
+			eq.sortedKeys = append(eq.sortedKeys[:i], eq.sortedKeys[i+1:]...)
+			break
+		}
+	}
 	data := eq.sortedKeys
 	vMap := eq.ttlMap
 	eq.ttlMap[x] = ttl
@@ -44,8 +55,16 @@ func (eq *ExpiryQueue) insertKey(x string, ttl int64) {
 	eq.sortedKeys = data
 }
 
+// Stop expiring keys, and close the out channel once done
+func (eq *ExpiryQueue) close() {
+	eq.closeOnce.Do(func() {
+		close(eq.done)
+	})
+}
+
 // Expire the keys in a timed loop with intervals of 1 second
 func (eq *ExpiryQueue) expireKeys() {
+	defer close(eq.out)
 	currSec := time.Now().Unix()
 	// Useful when listener goroutine is shutdown
 	exitFlag := false
@@ -59,21 +78,21 @@ func (eq *ExpiryQueue) expireKeys() {
 				// Create a copy of keys so that we do not use the original array which might be modified
 				// by the time we end
 				keys := eq.sortedKeys
-				till := 1
+				till := len(keys)
 				// Get first key
 				for i := 0; i < len(keys); i++ {
 					k := keys[i]
 					if eq.ttlMap[k] <= currSec {
-						// Perform conditional write, if there are no listeners
-						// log warning
+						// Wait for the listener, unless the queue was closed
 						select {
 						case eq.out <- k:
-						default:
-							// Here we break out of goroutine, because there are no more listeners left
+						case <-eq.done:
 							exitFlag = true
-							break
 						}
 						delete(eq.ttlMap, k)
+						if exitFlag == true {
+							break
+						}
 					} else {
 						till = i
 						break
@@ -84,8 +103,15 @@ func (eq *ExpiryQueue) expireKeys() {
 			}
 		}
 		eq.mux.Unlock()
+		if exitFlag == true {
+			break
+		}
 		// Sleep for 1 second
-		time.Sleep(1 * time.Second)
+		select {
+		case <-time.After(1 * time.Second):
+		case <-eq.done:
+			exitFlag = true
+		}
 		// This is approximate, if deleting takes more than 1 second because
 		// the reader is blocked, we need to add difference
 		currSec += time.Now().Unix() - start
@@ -2,6 +2,7 @@
 package storage
 
 import (
+	"sort"
 	"sync"
 	"time"
 )
@@ -9,22 +10,26 @@ import (
 // The following concurrent map implementation is based on the following source:
 // https://medium.com/@deckarep/the-new-kid-in-town-gos-sync-map-de24a6bf7c2c
 
-// GenericConcurrentMap maps a string key to a int or string value
+// GenericConcurrentMap maps a string key to a binary-safe value. Values are
+// stored as raw bytes, so that whatever was stored is returned unchanged
 type GenericConcurrentMap struct {
 	sync.RWMutex
-	internal map[string]string
-	eq       *ExpiryQueue
+	internal map[string][]byte
+	// When keys with a time to live expire. Keys past their expiry are
+	// treated as missing until the expiry queue deletes them
+	expires map[string]time.Time
+	eq      *ExpiryQueue
 }
 
 // NewGenericConcurrentMap creates a new string > int or string map
 func NewGenericConcurrentMap() *GenericConcurrentMap {
 	eq := NewExpiryQueue()
 	gm := GenericConcurrentMap{
-		internal: make(map[string]string),
+		internal: make(map[string][]byte),
+		expires:  make(map[string]time.Time),
 		eq:       eq,
 	}
 	go gm.expireKey(eq.out)
-	defer close(eq.out)
 	return &gm
 }
 
@@ -34,21 +39,109 @@ func (gcm *GenericConcurrentMap) expireKey(out chan string) {
 		if o != true {
 			break
 		}
-		gcm.Delete(key)
+		gcm.deleteExpired(key)
 	}
 }
 
-// SetExpiry sets the expiry value for key.
+// Delete key if it expired. The queue may hold keys whose expiry was removed
+// or changed since, those are kept
+func (gcm *GenericConcurrentMap) deleteExpired(key string) {
+	gcm.Lock()
+	defer gcm.Unlock()
+	if gcm.expired(key, time.Now()) {
+		delete(gcm.internal, key)
+		delete(gcm.expires, key)
+	}
+}
+
+// Check if key has an expiry that passed. Must be called with the lock held
+func (gcm *GenericConcurrentMap) expired(key string, now time.Time) bool {
+	deadline, ok := gcm.expires[key]
+	return ok && !deadline.After(now)
+}
+
+// Close stops expiring keys. The map can still be used, but keys with an
+// expiry are kept
+func (gcm *GenericConcurrentMap) Close() {
+	gcm.eq.close()
+}
+
+// SetExpiry sets the expiry value for key, in seconds from now.
 func (gcm *GenericConcurrentMap) SetExpiry(key string, ttl int64) {
-	currSec := time.Now().Unix()
-	// Add current time in seconds, so that we use absolute number of seconds since epoch
-	gcm.eq.insertKey(key, currSec+ttl)
+	gcm.SetExpiryAt(key, time.Now().Add(time.Duration(ttl)*time.Second))
+}
+
+// SetExpiryAt makes key expire at deadline. Storing a new value at key
+// removes the expiry
+func (gcm *GenericConcurrentMap) SetExpiryAt(key string, deadline time.Time) {
+	gcm.Lock()
+	gcm.expires[key] = deadline
+	gcm.Unlock()
+	gcm.queueExpiry(key, deadline)
+}
+
+// ExpireAt makes key expire at deadline, like SetExpiryAt, but only if the
+// key exists. The check and the change are atomic, so a key deleted meanwhile
+// does not leave its expiry behind for the next value stored at it. Returns
+// false if the key does not exist
+func (gcm *GenericConcurrentMap) ExpireAt(key string, deadline time.Time) bool {
+	gcm.Lock()
+	_, ok := gcm.internal[key]
+	if !ok || gcm.expired(key, time.Now()) {
+		gcm.Unlock()
+		return false
+	}
+	gcm.expires[key] = deadline
+	gcm.Unlock()
+	gcm.queueExpiry(key, deadline)
+	return true
+}
+
+// Queue key to be deleted once deadline passes
+func (gcm *GenericConcurrentMap) queueExpiry(key string, deadline time.Time) {
+	// The queue works in whole seconds, so round up to not expire early
+	seconds := deadline.Unix()
+	if deadline.After(time.Unix(seconds, 0)) {
+		seconds++
+	}
+	gcm.eq.insertKey(key, seconds)
+}
+
+// Expiry returns when key expires, and false if it exists without an expiry,
+// or does not exist at all
+func (gcm *GenericConcurrentMap) Expiry(key string) (time.Time, bool) {
+	gcm.RLock()
+	defer gcm.RUnlock()
+	if gcm.expired(key, time.Now()) {
+		return time.Time{}, false
+	}
+	deadline, ok := gcm.expires[key]
+	return deadline, ok
+}
+
+// Keys returns all keys in the map, sorted
+func (gcm *GenericConcurrentMap) Keys() []string {
+	gcm.RLock()
+	defer gcm.RUnlock()
+	now := time.Now()
+	keys := make([]string, 0, len(gcm.internal))
+	for key := range gcm.internal {
+		if !gcm.expired(key, now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-// Load a new value from the map or nil, if it does not exist
-func (gcm *GenericConcurrentMap) Load(key string) (value string, ok bool) {
+// Load a new value from the map or nil, if it does not exist. The returned
+// slice must not be modified, Store a new value instead
+func (gcm *GenericConcurrentMap) Load(key string) (value []byte, ok bool) {
 	gcm.RLock()
 	defer gcm.RUnlock()
+	if gcm.expired(key, time.Now()) {
+		return nil, false
+	}
 	result, ok := gcm.internal[key]
 	return result, ok
 }
@@ -58,19 +151,44 @@ func (gcm *GenericConcurrentMap) Delete(key string) bool {
 	gcm.Lock()
 	defer gcm.Unlock()
 	_, ok := gcm.internal[key]
-	if ok == false {
+	if ok == false || gcm.expired(key, time.Now()) {
+		delete(gcm.internal, key)
+		delete(gcm.expires, key)
 		return false
 	}
 	// Delete is a no-op if key does not exist. Without a lock, we may end up deleting
 	// an item that is not written or vice. We use a return value explicitly by invoking
 	// a read. Since the read is performed after a lock, we are okay
 	delete(gcm.internal, key)
+	delete(gcm.expires, key)
 	return true
 }
 
-// Store a given int or string value at given key
-func (gcm *GenericConcurrentMap) Store(key string, value string) {
+// Store a given value at given key, removing any expiry. The map takes
+// ownership of value, so the caller must not modify it afterwards
+func (gcm *GenericConcurrentMap) Store(key string, value []byte) {
 	gcm.Lock()
 	defer gcm.Unlock()
 	gcm.internal[key] = value
+	delete(gcm.expires, key)
+}
+
+// Update atomically replaces the value at key with the one returned by update,
+// which is given the current value. Nothing is stored if update returns an
+// error, which is passed on to the caller. Like Load, update must not modify
+// the value it is given. The expiry of key is kept.
+func (gcm *GenericConcurrentMap) Update(key string, update func(value []byte, ok bool) ([]byte, error)) error {
+	gcm.Lock()
+	defer gcm.Unlock()
+	if gcm.expired(key, time.Now()) {
+		delete(gcm.internal, key)
+		delete(gcm.expires, key)
+	}
+	value, ok := gcm.internal[key]
+	updated, err := update(value, ok)
+	if err != nil {
+		return err
+	}
+	gcm.internal[key] = updated
+	return nil
 }
@@ -1,30 +1,41 @@
 package storage
 
 import (
+	"errors"
 	"runtime"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConcurrentMapSingleClientStoreAndLoad(t *testing.T) {
 	m := NewGenericConcurrentMap()
-	m.Store("foo", "bar")
-	m.Store("foo2", "2")
+	m.Store("foo", []byte("bar"))
+	m.Store("foo2", []byte("2"))
 	val, ok := m.Load("foo")
 	assert.Equal(t, ok, true)
-	assert.Equal(t, val, "bar")
+	assert.Equal(t, val, []byte("bar"))
 	val, ok = m.Load("foo2")
-	assert.Equal(t, val, "2")
+	assert.Equal(t, val, []byte("2"))
 	_, ok = m.Load("foo3")
 	assert.Equal(t, ok, false)
 }
 
+func TestConcurrentMapBinaryValues(t *testing.T) {
+	m := NewGenericConcurrentMap()
+	binary := []byte{0x1f, 0x8b, 0x00, '\r', '\n', 0xff}
+	m.Store("gz", binary)
+	val, ok := m.Load("gz")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, val, binary, "Binary values must be returned unchanged")
+}
+
 func TestConcurrentSingleClientMapDelete(t *testing.T) {
 	m := NewGenericConcurrentMap()
-	m.Store("foo", "bar")
-	m.Store("foo2", "2")
+	m.Store("foo", []byte("bar"))
+	m.Store("foo2", []byte("2"))
 	ok := m.Delete("foo")
 	assert.Equal(t, ok, true)
 	ok = m.Delete("foo2")
@@ -35,11 +46,11 @@ func TestConcurrentSingleClientMapDelete(t *testing.T) {
 
 func reader(t *testing.T, g *GenericConcurrentMap, c chan string, key string) {
 	v, _ := g.Load(key)
-	c <- v
+	c <- string(v)
 }
 
 func writer(g *GenericConcurrentMap, c chan string, key string, value string) {
-	g.Store(key, value)
+	g.Store(key, []byte(value))
 	c <- value
 }
 
@@ -49,7 +60,7 @@ func TestConcurrentMapAccessMultipleClients(t *testing.T) {
 	// Ideas for this test are taken from https://golang.org/src/runtime/rwmutex_test.go
 	m := NewGenericConcurrentMap()
 	// Store initial value
-	m.Store("foo", "omg")
+	m.Store("foo", []byte("omg"))
 
 	c := make(chan string, 1)
 	done := make(chan string)
@@ -67,7 +78,7 @@ func TestConcurrentMapAccessMultipleClients(t *testing.T) {
 	assert.Equal(t, <-c, "lol")
 
 	// Try concurrent reads without waiting, but waiting only on write
-	m.Store("foo", "lol")
+	m.Store("foo", []byte("lol"))
 	go reader(t, m, c, "foo")
 	go reader(t, m, c, "foo")
 	go writer(m, done, "foo", "lol2")
@@ -107,32 +118,129 @@ func TestConcurrentMapWriteMultipleWriters(t *testing.T) {
 func TestConcurrentMapWriteAndDelete(t *testing.T) {
 	m := NewGenericConcurrentMap()
 	var wg sync.WaitGroup
-	wg.Add(1)
+	deleted := make(chan bool)
 
 	// If we schedule one after each other, it may fail.
 	// There is no guarantee that write will finish first
 	// Here we use a waitgroup to wait for counter to go to zero
 
 	// Run write first
+	wg.Add(1)
 	go func() {
-		m.Store("foo", "2")
+		m.Store("foo", []byte("2"))
 		wg.Done()
 	}()
 	go func() {
 		wg.Wait()
 		// Waitgroup counter is now zero
-		ok := m.Delete("foo")
-		assert.Equal(t, ok, true)
+		deleted <- m.Delete("foo")
 	}()
+	assert.Equal(t, <-deleted, true)
+
+	// Now run delete first. Each goroutine reports on a channel of its own,
+	// since either may report first once the delete is done
+	stored := make(chan struct{})
 	wg.Add(1)
-	// Now run delete first
 	go func() {
 		wg.Wait()
-		m.Store("foo", "2")
+		m.Store("foo", []byte("2"))
+		close(stored)
 	}()
 	go func() {
 		ok := m.Delete("foo")
-		assert.Equal(t, ok, false)
 		wg.Done()
+		deleted <- ok
 	}()
+	assert.Equal(t, <-deleted, false)
+	<-stored
+	_, ok := m.Load("foo")
+	assert.Equal(t, ok, true)
+}
+
+func TestConcurrentMapUpdate(t *testing.T) {
+	m := NewGenericConcurrentMap()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Update("counter", func(value []byte, ok bool) ([]byte, error) {
+				return append(append([]byte{}, value...), 'x'), nil
+			})
+		}()
+	}
+	wg.Wait()
+	value, _ := m.Load("counter")
+	assert.Equal(t, 100, len(value), "Concurrent updates must not be lost")
+
+	failed := errors.New("failed")
+	err := m.Update("counter", func(value []byte, ok bool) ([]byte, error) {
+		return nil, failed
+	})
+	assert.Equal(t, failed, err)
+	value, _ = m.Load("counter")
+	assert.Equal(t, 100, len(value), "A failed update must not store anything")
+}
+
+func TestConcurrentMapExpiry(t *testing.T) {
+	m := NewGenericConcurrentMap()
+	defer m.Close()
+	m.Store("foo", []byte("bar"))
+	_, ok := m.Expiry("foo")
+	assert.False(t, ok, "Keys have no expiry unless one is set")
+	m.SetExpiry("foo", 100)
+	deadline, ok := m.Expiry("foo")
+	assert.True(t, ok)
+	assert.InDelta(t, 100, time.Until(deadline).Seconds(), 1)
+	m.Update("foo", func(value []byte, ok bool) ([]byte, error) {
+		return []byte("baz"), nil
+	})
+	_, ok = m.Expiry("foo")
+	assert.True(t, ok, "Updates must keep the expiry")
+	m.Store("foo", []byte("bar"))
+	_, ok = m.Expiry("foo")
+	assert.False(t, ok, "Storing a value must remove the expiry")
+
+	m.Store("expired", []byte("1"))
+	m.SetExpiryAt("expired", time.Now().Add(-time.Second))
+	_, ok = m.Load("expired")
+	assert.False(t, ok, "Expired keys must be missing before they are deleted")
+	assert.Equal(t, []string{"foo"}, m.Keys())
+	assert.False(t, m.Delete("expired"))
+}
+
+func TestConcurrentMapExpireAt(t *testing.T) {
+	m := NewGenericConcurrentMap()
+	defer m.Close()
+	assert.False(t, m.ExpireAt("missing", time.Now().Add(time.Minute)))
+	m.Update("missing", func(value []byte, ok bool) ([]byte, error) {
+		return []byte("1"), nil
+	})
+	_, ok := m.Expiry("missing")
+	assert.False(t, ok, "Missing keys must not be given an expiry")
+
+	m.Store("foo", []byte("bar"))
+	assert.True(t, m.ExpireAt("foo", time.Now().Add(time.Minute)))
+	_, ok = m.Expiry("foo")
+	assert.True(t, ok)
+	m.SetExpiryAt("foo", time.Now().Add(-time.Second))
+	assert.False(t, m.ExpireAt("foo", time.Now().Add(time.Minute)), "Expired keys count as missing")
+}
+
+func TestConcurrentMapExpiryDeletes(t *testing.T) {
+	m := NewGenericConcurrentMap()
+	defer m.Close()
+	m.Store("short", []byte("1"))
+	m.SetExpiryAt("short", time.Now().Add(10*time.Millisecond))
+	m.Store("stored-again", []byte("1"))
+	m.SetExpiryAt("stored-again", time.Now().Add(10*time.Millisecond))
+	m.Store("stored-again", []byte("2"))
+	time.Sleep(2100 * time.Millisecond)
+	m.RLock()
+	_, ok := m.internal["short"]
+	m.RUnlock()
+	assert.False(t, ok, "Expired keys must be deleted")
+	value, ok := m.Load("stored-again")
+	assert.True(t, ok, "Keys stored again must not expire")
+	assert.Equal(t, []byte("2"), value)
 }